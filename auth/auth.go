@@ -0,0 +1,219 @@
+// Package auth provides OAuth 2.0 token sources and a middleware authorizing
+// the requests with their access tokens, on top of an [httpc.Doer].
+//
+// The tokens are fetched with the client credentials (RFC 6749 Section 4.4)
+// or refresh token (RFC 6749 Section 6) grants, cached and refreshed before
+// they expire:
+//
+//	ts := auth.ClientCredentials(http.DefaultClient, auth.Config{
+//		TokenURL:     "https://auth.local/oauth/token",
+//		ClientID:     "client",
+//		ClientSecret: "secret",
+//		Scopes:       []string{"read"},
+//	})
+//	client := httpc.NewClient(http.DefaultClient, auth.Bearer(ts))
+package auth
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kraciasty/httpc"
+	"github.com/kraciasty/httpc/internal/challenge"
+	"github.com/kraciasty/httpc/internal/httpbody"
+)
+
+// DefaultExpirySkew is the default time before the expiry at which the
+// tokens are refreshed.
+const DefaultExpirySkew = 30 * time.Second
+
+// DefaultRefreshTimeout is the default time after which the token refreshes
+// are canceled.
+const DefaultRefreshTimeout = time.Minute
+
+// Token is an OAuth 2.0 token.
+type Token struct {
+	AccessToken  string    // The access token authorizing the requests.
+	TokenType    string    // The token type, usually "Bearer".
+	RefreshToken string    // The refresh token, if issued.
+	Expiry       time.Time // The expiry of the access token, zero if unknown.
+	Scope        string    // The scope of the access token, if sent.
+}
+
+// Valid reports whether the access token is set and not expired.
+func (t *Token) Valid() bool {
+	return t.validFor(0)
+}
+
+// validFor reports whether the token is valid for at least the duration.
+func (t *Token) validFor(d time.Duration) bool {
+	if t == nil || t.AccessToken == "" {
+		return false
+	}
+	return t.Expiry.IsZero() || time.Now().Add(d).Before(t.Expiry)
+}
+
+// TokenSource provides the tokens.
+type TokenSource interface {
+	Token(ctx context.Context) (*Token, error)
+}
+
+// TokenSourceFunc is an adapter to allow the use of ordinary functions as
+// a [TokenSource].
+type TokenSourceFunc func(ctx context.Context) (*Token, error)
+
+// Token calls f(ctx).
+func (f TokenSourceFunc) Token(ctx context.Context) (*Token, error) {
+	return f(ctx)
+}
+
+// CachedTokenSource is a [TokenSource] reusing the token of another source
+// until it expires.
+//
+// The token is refreshed when it expires within the skew. Concurrent calls
+// share a single refresh, which is not canceled when a caller's context is
+// done but when it exceeds the refresh timeout. When the refresh fails, the current token is returned as long as it
+// has not expired yet.
+type CachedTokenSource struct {
+	src     TokenSource
+	skew    time.Duration
+	timeout time.Duration
+
+	mu   sync.Mutex
+	tok  *Token
+	call *refreshCall
+}
+
+type refreshCall struct {
+	done chan struct{}
+	tok  *Token
+	err  error
+}
+
+// Cache returns a [CachedTokenSource] reusing the tokens of the source.
+// The skew defaults to [DefaultExpirySkew] when zero and the tokens are
+// reused until their expiry when below zero. The timeout defaults to
+// [DefaultRefreshTimeout] when zero, there is no timeout when below zero.
+func Cache(src TokenSource, skew, timeout time.Duration) *CachedTokenSource {
+	if skew == 0 {
+		skew = DefaultExpirySkew
+	}
+	if timeout == 0 {
+		timeout = DefaultRefreshTimeout
+	}
+	return &CachedTokenSource{src: src, skew: max(skew, 0), timeout: timeout}
+}
+
+// Token returns the cached token, refreshing it when it is about to expire.
+func (s *CachedTokenSource) Token(ctx context.Context) (*Token, error) {
+	s.mu.Lock()
+	tok := s.tok
+	if tok.validFor(s.skew) {
+		s.mu.Unlock()
+		return tok, nil
+	}
+
+	c := s.call
+	if c == nil {
+		c = &refreshCall{done: make(chan struct{})}
+		s.call = c
+		go s.refresh(context.WithoutCancel(ctx), c)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.done:
+	}
+
+	if c.err != nil {
+		if tok.Valid() {
+			return tok, nil
+		}
+		return nil, c.err
+	}
+	return c.tok, nil
+}
+
+func (s *CachedTokenSource) refresh(ctx context.Context, c *refreshCall) {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	tok, err := s.src.Token(ctx)
+
+	s.mu.Lock()
+	if err == nil {
+		s.tok = tok
+	}
+	s.call = nil
+	s.mu.Unlock()
+
+	c.tok, c.err = tok, err
+	close(c.done)
+}
+
+// Invalidate drops the cached token if it is the provided one, e.g. after
+// it was rejected by the server, so that the next call fetches a new one.
+func (s *CachedTokenSource) Invalidate(tok *Token) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.tok != nil && tok != nil && s.tok.AccessToken == tok.AccessToken {
+		s.tok = nil
+	}
+}
+
+// Bearer is a middleware that sets the access token of the source in the
+// Authorization header.
+//
+// When the server rejects the token with a 401 Unauthorized response and
+// a Bearer challenge with the invalid_token error, the token is invalidated
+// and the request is retried once with a new token. Requests with a body are
+// retried only if the body can be rewound with [http.Request.GetBody].
+func Bearer(ts TokenSource) httpc.MiddlewareFunc {
+	return func(next httpc.DoerFunc) httpc.DoerFunc {
+		return func(r *http.Request) (*http.Response, error) {
+			tok, err := ts.Token(r.Context())
+			if err != nil {
+				return nil, err
+			}
+			r.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+
+			resp, err := next(r)
+			if err != nil || !invalidToken(resp) || !httpbody.Rewindable(r) {
+				return resp, err
+			}
+
+			if inv, ok := ts.(interface{ Invalidate(*Token) }); ok {
+				inv.Invalidate(tok)
+			}
+			tok, err = ts.Token(r.Context())
+			if err != nil {
+				// Return the rejection, the server's answer for the request.
+				return resp, nil
+			}
+			httpbody.Drain(resp)
+
+			retry, err := httpbody.Rewind(r)
+			if err != nil {
+				return nil, err
+			}
+			retry.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+			return next(retry)
+		}
+	}
+}
+
+// invalidToken reports whether the response rejects the access token.
+func invalidToken(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusUnauthorized {
+		return false
+	}
+	c, ok := challenge.Find(resp.Header, "Bearer")
+	return ok && c.Params["error"] == "invalid_token"
+}
@@ -0,0 +1,412 @@
+package auth_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/kraciasty/httpc"
+	"github.com/kraciasty/httpc/auth"
+)
+
+// A token endpoint issuing numbered access tokens. It records the forms and
+// the Authorization headers of the requests.
+type tokenServer struct {
+	mu        sync.Mutex
+	forms     []url.Values
+	auths     []string
+	expiresIn int
+	delay     time.Duration
+	fail      bool
+}
+
+func (s *tokenServer) Do(r *http.Request) (*http.Response, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	time.Sleep(s.delay)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.forms = append(s.forms, r.PostForm)
+	s.auths = append(s.auths, r.Header.Get("Authorization"))
+
+	if s.fail {
+		return jsonResponse(http.StatusBadRequest, map[string]any{
+			"error":             "invalid_client",
+			"error_description": "unknown client",
+		}), nil
+	}
+
+	n := len(s.forms)
+	return jsonResponse(http.StatusOK, map[string]any{
+		"access_token":  fmt.Sprintf("access-%d", n),
+		"token_type":    "Bearer",
+		"refresh_token": fmt.Sprintf("refresh-%d", n),
+		"expires_in":    s.expiresIn,
+	}), nil
+}
+
+func (s *tokenServer) calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.forms)
+}
+
+func jsonResponse(status int, v any) *http.Response {
+	b, _ := json.Marshal(v)
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(string(b))),
+	}
+}
+
+func token(t *testing.T, ts auth.TokenSource) string {
+	t.Helper()
+
+	tok, err := ts.Token(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return tok.AccessToken
+}
+
+func ExampleClientCredentials() {
+	srv := &tokenServer{expiresIn: 3600}
+	ts := auth.ClientCredentials(srv, auth.Config{
+		TokenURL:     "https://auth.local/oauth/token",
+		ClientID:     "client",
+		ClientSecret: "secret",
+	})
+
+	api := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+		fmt.Println(r.Header.Get("Authorization"))
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+	c := httpc.NewClient(api, auth.Bearer(ts))
+
+	r, _ := http.NewRequest(http.MethodGet, "https://api.local", http.NoBody)
+	resp, _ := c.Do(r)
+	defer resp.Body.Close()
+	// Output: Bearer access-1
+}
+
+func TestClientCredentials_request(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      auth.Config
+		wantForm url.Values
+		wantAuth string
+	}{
+		{
+			name: "header",
+			cfg: auth.Config{
+				ClientID:     "client:1",
+				ClientSecret: "s&cret",
+				Scopes:       []string{"read", "write"},
+				Params:       url.Values{"audience": {"api"}},
+			},
+			wantForm: url.Values{
+				"grant_type": {"client_credentials"},
+				"scope":      {"read write"},
+				"audience":   {"api"},
+			},
+			wantAuth: "Basic Y2xpZW50JTNBMTpzJTI2Y3JldA==",
+		},
+		{
+			name: "params",
+			cfg: auth.Config{
+				ClientID:     "client",
+				ClientSecret: "secret",
+				AuthStyle:    auth.AuthStyleParams,
+			},
+			wantForm: url.Values{
+				"grant_type":    {"client_credentials"},
+				"client_id":     {"client"},
+				"client_secret": {"secret"},
+			},
+		},
+		{
+			name: "reserved params",
+			cfg: auth.Config{
+				ClientID:     "client",
+				ClientSecret: "secret",
+				Scopes:       []string{"read"},
+				AuthStyle:    auth.AuthStyleParams,
+				Params: url.Values{
+					"grant_type":    {"password"},
+					"scope":         {"admin"},
+					"client_id":     {"other"},
+					"client_secret": {"other"},
+					"audience":      {"api"},
+				},
+			},
+			wantForm: url.Values{
+				"grant_type":    {"client_credentials"},
+				"scope":         {"read"},
+				"client_id":     {"client"},
+				"client_secret": {"secret"},
+				"audience":      {"api"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &tokenServer{}
+			tt.cfg.TokenURL = "https://auth.local/token"
+
+			if got := token(t, auth.ClientCredentials(srv, tt.cfg)); got != "access-1" {
+				t.Errorf("Expected access token %q but got %q", "access-1", got)
+			}
+			if got := srv.forms[0].Encode(); got != tt.wantForm.Encode() {
+				t.Errorf("Expected form %q but got %q", tt.wantForm.Encode(), got)
+			}
+			if srv.auths[0] != tt.wantAuth {
+				t.Errorf("Expected Authorization %q but got %q", tt.wantAuth, srv.auths[0])
+			}
+		})
+	}
+}
+
+func TestClientCredentials_refresh(t *testing.T) {
+	synctest.Run(func() {
+		srv := &tokenServer{expiresIn: 60}
+		ts := auth.ClientCredentials(srv, auth.Config{TokenURL: "https://auth.local/token"})
+
+		steps := []struct {
+			after time.Duration
+			want  string
+		}{
+			{0, "access-1"},
+			{20 * time.Second, "access-1"},
+			{11 * time.Second, "access-2"}, // Within the default skew.
+			{29 * time.Second, "access-2"},
+		}
+		for _, s := range steps {
+			time.Sleep(s.after)
+			if got := token(t, ts); got != s.want {
+				t.Errorf("Expected access token %q after %v but got %q", s.want, s.after, got)
+			}
+		}
+	})
+}
+
+func TestClientCredentials_singleflight(t *testing.T) {
+	synctest.Run(func() {
+		srv := &tokenServer{delay: time.Second}
+		ts := auth.ClientCredentials(srv, auth.Config{TokenURL: "https://auth.local/token"})
+
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if got := token(t, ts); got != "access-1" {
+					t.Errorf("Expected access token %q but got %q", "access-1", got)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if srv.calls() != 1 {
+			t.Errorf("Expected 1 token request but got %d", srv.calls())
+		}
+	})
+}
+
+func TestClientCredentials_canceled(t *testing.T) {
+	synctest.Run(func() {
+		srv := &tokenServer{delay: time.Second}
+		ts := auth.ClientCredentials(srv, auth.Config{TokenURL: "https://auth.local/token"})
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+		if _, err := ts.Token(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected context.DeadlineExceeded but got: %v", err)
+		}
+
+		// The refresh continues for the other callers.
+		if got := token(t, ts); got != "access-1" {
+			t.Errorf("Expected access token %q but got %q", "access-1", got)
+		}
+		if srv.calls() != 1 {
+			t.Errorf("Expected 1 token request but got %d", srv.calls())
+		}
+	})
+}
+
+func TestCache_refreshTimeout(t *testing.T) {
+	synctest.Run(func() {
+		// The source hangs until the refresh is canceled.
+		src := auth.TokenSourceFunc(func(ctx context.Context) (*auth.Token, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+		ts := auth.Cache(src, 0, time.Second)
+
+		start := time.Now()
+		if _, err := ts.Token(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected context.DeadlineExceeded but got: %v", err)
+		}
+		if got := time.Since(start); got != time.Second {
+			t.Errorf("Expected the refresh to be canceled after %v but got %v", time.Second, got)
+		}
+	})
+}
+
+func TestClientCredentials_error(t *testing.T) {
+	synctest.Run(func() {
+		srv := &tokenServer{expiresIn: 60}
+		ts := auth.ClientCredentials(srv, auth.Config{TokenURL: "https://auth.local/token"})
+		_ = token(t, ts)
+
+		// The current token is used while the refresh fails.
+		srv.fail = true
+		time.Sleep(45 * time.Second)
+		if got := token(t, ts); got != "access-1" {
+			t.Errorf("Expected access token %q but got %q", "access-1", got)
+		}
+
+		time.Sleep(15 * time.Second)
+		_, err := ts.Token(context.Background())
+		if !errors.Is(err, auth.ErrTokenRequest) {
+			t.Fatalf("Expected auth.ErrTokenRequest but got: %v", err)
+		}
+
+		var tokenErr *auth.TokenError
+		if !errors.As(err, &tokenErr) || tokenErr.Code != "invalid_client" || tokenErr.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected a token error with the invalid_client code but got: %v", err)
+		}
+		if want := "token request failed: status 400: invalid_client: unknown client"; err.Error() != want {
+			t.Errorf("Expected error %q but got %q", want, err)
+		}
+	})
+}
+
+func TestRefreshToken(t *testing.T) {
+	srv := &tokenServer{}
+	ts := auth.RefreshToken(srv, auth.Config{TokenURL: "https://auth.local/token"}, "initial")
+
+	tok, err := ts.Token(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ts.Invalidate(tok)
+	if got := token(t, ts); got != "access-2" {
+		t.Errorf("Expected access token %q but got %q", "access-2", got)
+	}
+
+	var got []string
+	for _, form := range srv.forms {
+		if form.Get("grant_type") != "refresh_token" {
+			t.Errorf("Expected the refresh_token grant but got %q", form.Get("grant_type"))
+		}
+		got = append(got, form.Get("refresh_token"))
+	}
+	if want := "initial,refresh-1"; strings.Join(got, ",") != want {
+		t.Errorf("Expected refresh tokens %q but got %q", want, got)
+	}
+}
+
+func TestBearer_retry(t *testing.T) {
+	tests := []struct {
+		name      string
+		challenge string
+		getBody   bool
+		wantAuths []string
+	}{
+		{
+			name:      "invalid token",
+			challenge: `Bearer realm="api", error="invalid_token", error_description="expired"`,
+			getBody:   true,
+			wantAuths: []string{"Bearer access-1", "Bearer access-2"},
+		},
+		{
+			name:      "other error",
+			challenge: `Bearer realm="api", error="insufficient_scope"`,
+			getBody:   true,
+			wantAuths: []string{"Bearer access-1"},
+		},
+		{
+			name:      "body cannot be rewound",
+			challenge: `Bearer error="invalid_token"`,
+			wantAuths: []string{"Bearer access-1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := auth.ClientCredentials(&tokenServer{}, auth.Config{TokenURL: "https://auth.local/token"})
+
+			var auths, bodies []string
+			api := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+				b, _ := io.ReadAll(r.Body)
+				auths = append(auths, r.Header.Get("Authorization"))
+				bodies = append(bodies, string(b))
+				if len(auths) == 1 {
+					return &http.Response{
+						StatusCode: http.StatusUnauthorized,
+						Header:     http.Header{"Www-Authenticate": {tt.challenge}},
+						Body:       http.NoBody,
+					}, nil
+				}
+				return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+			})
+			c := httpc.NewClient(api, auth.Bearer(ts))
+
+			r, err := http.NewRequest(http.MethodPost, "https://api.local", strings.NewReader("payload"))
+			if err != nil {
+				t.Fatalf("Cannot create request: %v", err)
+			}
+			if !tt.getBody {
+				r.GetBody = nil
+			}
+
+			resp, err := c.Do(r)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if strings.Join(auths, ",") != strings.Join(tt.wantAuths, ",") {
+				t.Errorf("Expected Authorization headers %q but got %q", tt.wantAuths, auths)
+			}
+			for _, b := range bodies {
+				if b != "payload" {
+					t.Errorf("Expected the body to be sent but got %q", b)
+				}
+			}
+			if wantStatus := []int{http.StatusUnauthorized, http.StatusOK}[len(tt.wantAuths)-1]; resp.StatusCode != wantStatus {
+				t.Errorf("Expected status %d but got %d", wantStatus, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestBearer_tokenError(t *testing.T) {
+	ts := auth.ClientCredentials(&tokenServer{fail: true}, auth.Config{TokenURL: "https://auth.local/token"})
+	api := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+		t.Errorf("Unexpected request without a token")
+		return nil, errors.New("unexpected request")
+	})
+	c := httpc.NewClient(api, auth.Bearer(ts))
+
+	r, err := http.NewRequest(http.MethodGet, "https://api.local", http.NoBody)
+	if err != nil {
+		t.Fatalf("Cannot create request: %v", err)
+	}
+	if _, err := c.Do(r); !errors.Is(err, auth.ErrTokenRequest) {
+		t.Errorf("Expected auth.ErrTokenRequest but got: %v", err)
+	}
+}
@@ -0,0 +1,217 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kraciasty/httpc"
+	"github.com/kraciasty/httpc/internal/httpbody"
+)
+
+// ErrTokenRequest indicates that the token endpoint did not issue a token.
+var ErrTokenRequest = errors.New("token request failed")
+
+// maxTokenResponseBytes limits the size of the token endpoint responses.
+const maxTokenResponseBytes = 1 << 20
+
+// TokenError is returned when the token endpoint rejects the request,
+// with the error response of RFC 6749 Section 5.2 if sent.
+type TokenError struct {
+	StatusCode  int    // The HTTP status code of the response.
+	Code        string // The error code, e.g. "invalid_client".
+	Description string // The human-readable error description, if sent.
+	URI         string // The URI of the error page, if sent.
+}
+
+// Error returns a string representation of the error.
+func (e *TokenError) Error() string {
+	msg := fmt.Sprintf("%v: status %d", ErrTokenRequest, e.StatusCode)
+	if e.Code != "" {
+		msg += ": " + e.Code
+	}
+	if e.Description != "" {
+		msg += ": " + e.Description
+	}
+	return msg
+}
+
+// Is compares the [ErrTokenRequest] with the target error.
+func (e *TokenError) Is(target error) bool {
+	return errors.Is(target, ErrTokenRequest)
+}
+
+// AuthStyle is the way the client authenticates with the token endpoint.
+type AuthStyle int
+
+const (
+	// AuthStyleHeader sends the client credentials with HTTP Basic
+	// authentication, as recommended by RFC 6749 Section 2.3.1.
+	AuthStyleHeader AuthStyle = iota
+
+	// AuthStyleParams sends the client credentials in the request body.
+	AuthStyleParams
+)
+
+// Config configures the OAuth 2.0 client of the token sources.
+type Config struct {
+	// TokenURL is the URL of the token endpoint.
+	TokenURL string
+
+	// ClientID and ClientSecret are the client credentials.
+	ClientID     string
+	ClientSecret string
+
+	// Scopes are the requested scopes, optional.
+	Scopes []string
+
+	// Params are additional parameters of the token requests, e.g. the
+	// audience. They do not override the grant parameters, the scope and the
+	// client credentials.
+	Params url.Values
+
+	// AuthStyle is the way the client authenticates.
+	// Defaults to [AuthStyleHeader].
+	AuthStyle AuthStyle
+
+	// ExpirySkew is the time before the expiry at which the tokens are
+	// refreshed. Defaults to [DefaultExpirySkew] when zero, there is no skew
+	// when below zero.
+	ExpirySkew time.Duration
+
+	// RefreshTimeout is the time after which the token requests are canceled.
+	// Defaults to [DefaultRefreshTimeout] when zero, there is no timeout when
+	// below zero.
+	RefreshTimeout time.Duration
+}
+
+// ClientCredentials returns a token source for the client credentials grant
+// (RFC 6749 Section 4.4). The tokens are requested with the doer and cached
+// until they expire.
+func ClientCredentials(d httpc.Doer, cfg Config) *CachedTokenSource {
+	src := TokenSourceFunc(func(ctx context.Context) (*Token, error) {
+		return fetchToken(ctx, d, cfg, url.Values{"grant_type": {"client_credentials"}})
+	})
+	return Cache(src, cfg.ExpirySkew, cfg.RefreshTimeout)
+}
+
+// RefreshToken returns a token source for the refresh token grant (RFC 6749
+// Section 6), e.g. of a token obtained with the authorization code flow.
+// The tokens are requested with the doer and cached until they expire.
+// A new refresh token issued by the server replaces the previous one.
+func RefreshToken(d httpc.Doer, cfg Config, refreshToken string) *CachedTokenSource {
+	src := &refreshSource{doer: d, cfg: cfg, refreshToken: refreshToken}
+	return Cache(src, cfg.ExpirySkew, cfg.RefreshTimeout)
+}
+
+type refreshSource struct {
+	doer httpc.Doer
+	cfg  Config
+
+	mu           sync.Mutex
+	refreshToken string
+}
+
+func (s *refreshSource) Token(ctx context.Context) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tok, err := fetchToken(ctx, s.doer, s.cfg, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {s.refreshToken},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if tok.RefreshToken == "" {
+		tok.RefreshToken = s.refreshToken
+	}
+	s.refreshToken = tok.RefreshToken
+	return tok, nil
+}
+
+type tokenResponse struct {
+	AccessToken  string      `json:"access_token"`
+	TokenType    string      `json:"token_type"`
+	RefreshToken string      `json:"refresh_token"`
+	ExpiresIn    json.Number `json:"expires_in"`
+	Scope        string      `json:"scope"`
+
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+	ErrorURI         string `json:"error_uri"`
+}
+
+// fetchToken requests a token from the token endpoint with the grant params.
+func fetchToken(ctx context.Context, d httpc.Doer, cfg Config, grant url.Values) (*Token, error) {
+	form := make(url.Values)
+	for k, v := range cfg.Params {
+		form[k] = slices.Clone(v)
+	}
+	for k, v := range grant {
+		form[k] = v
+	}
+	if len(cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(cfg.Scopes, " "))
+	}
+	if cfg.AuthStyle == AuthStyleParams {
+		form.Set("client_id", cfg.ClientID)
+		if cfg.ClientSecret != "" {
+			form.Set("client_secret", cfg.ClientSecret)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if cfg.AuthStyle == AuthStyleHeader {
+		req.SetBasicAuth(url.QueryEscape(cfg.ClientID), url.QueryEscape(cfg.ClientSecret))
+	}
+
+	resp, err := d.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer httpbody.Drain(resp)
+
+	var body tokenResponse
+	decodeErr := json.NewDecoder(io.LimitReader(resp.Body, maxTokenResponseBytes)).Decode(&body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 || body.Error != "" {
+		return nil, &TokenError{
+			StatusCode:  resp.StatusCode,
+			Code:        body.Error,
+			Description: body.ErrorDescription,
+			URI:         body.ErrorURI,
+		}
+	}
+	if decodeErr != nil {
+		return nil, fmt.Errorf("%w: decode response: %w", ErrTokenRequest, decodeErr)
+	}
+	if body.AccessToken == "" {
+		return nil, fmt.Errorf("%w: no access_token in the response", ErrTokenRequest)
+	}
+
+	tok := &Token{
+		AccessToken:  body.AccessToken,
+		TokenType:    body.TokenType,
+		RefreshToken: body.RefreshToken,
+		Scope:        body.Scope,
+	}
+	if secs, err := body.ExpiresIn.Int64(); err == nil && secs > 0 {
+		tok.Expiry = time.Now().Add(time.Duration(secs) * time.Second)
+	}
+	return tok, nil
+}
@@ -0,0 +1,160 @@
+package httpc
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrBulkheadFull indicates that the request was rejected because the limit
+// of in-flight requests was reached and it could not be queued.
+var ErrBulkheadFull = errors.New("bulkhead full")
+
+// BulkheadOptions configures the [Bulkhead] middleware.
+type BulkheadOptions struct {
+	// Limit is the maximum number of in-flight requests for each key.
+	// The middleware is not applied if the limit is below or equal to zero.
+	Limit int
+
+	// Key returns the bulkhead key for the request.
+	// A nil key function limits all requests together.
+	// Use [HostKey] to limit the requests per host.
+	Key func(*http.Request) string
+
+	// MaxQueue is the maximum number of requests waiting for a slot for each
+	// key. Zero means no limit and a negative value disables queueing.
+	MaxQueue int
+
+	// QueueTimeout is the maximum time a request waits for a slot.
+	// Zero means waiting until the request context is done.
+	QueueTimeout time.Duration
+}
+
+// MaxInFlight is a middleware that limits the number of concurrent in-flight
+// requests to n for each key.
+//
+// It is a shorthand for the [Bulkhead] middleware with an unbounded queue.
+func MaxInFlight(n int, key func(*http.Request) string) MiddlewareFunc {
+	return Bulkhead(BulkheadOptions{Limit: n, Key: key})
+}
+
+// Bulkhead is a middleware that limits the number of concurrent in-flight
+// requests for each key, as configured by the [BulkheadOptions].
+//
+// Excess requests are queued until a slot is freed, the queue timeout passes
+// or the request context is done. Requests that cannot be queued fail with
+// [ErrBulkheadFull].
+//
+// A slot is held until the response body is fully read or closed, so that
+// streaming responses count as in-flight until they are consumed.
+func Bulkhead(opts BulkheadOptions) MiddlewareFunc {
+	if opts.Limit <= 0 {
+		return nil
+	}
+
+	key := opts.Key
+	if key == nil {
+		key = func(*http.Request) string { return "" }
+	}
+
+	var (
+		mu        sync.Mutex
+		bulkheads = make(map[string]*bulkhead)
+	)
+
+	get := func(k string) *bulkhead {
+		mu.Lock()
+		defer mu.Unlock()
+
+		b, ok := bulkheads[k]
+		if !ok {
+			b = &bulkhead{slots: make(chan struct{}, opts.Limit)}
+			bulkheads[k] = b
+		}
+		return b
+	}
+
+	return func(next DoerFunc) DoerFunc {
+		return func(r *http.Request) (*http.Response, error) {
+			b := get(key(r))
+			if err := b.acquire(r, opts); err != nil {
+				return nil, err
+			}
+
+			// The slot is released with the response body, or right away
+			// when there is no body or next panics.
+			release := sync.OnceFunc(b.release)
+			wrapped := false
+			defer func() {
+				if !wrapped {
+					release()
+				}
+			}()
+
+			resp, err := next(r)
+			if err != nil || resp == nil || resp.Body == nil {
+				return resp, err
+			}
+
+			resp.Body = &timeoutBody{
+				ReadCloser: resp.Body,
+				cancel:     release,
+			}
+			wrapped = true
+
+			return resp, nil
+		}
+	}
+}
+
+// bulkhead holds the slots of a single key.
+type bulkhead struct {
+	slots chan struct{}
+
+	mu     sync.Mutex
+	queued int
+}
+
+func (b *bulkhead) acquire(r *http.Request, opts BulkheadOptions) error {
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	b.mu.Lock()
+	if opts.MaxQueue < 0 || (opts.MaxQueue > 0 && b.queued >= opts.MaxQueue) {
+		b.mu.Unlock()
+		return ErrBulkheadFull
+	}
+	b.queued++
+	b.mu.Unlock()
+
+	defer func() {
+		b.mu.Lock()
+		b.queued--
+		b.mu.Unlock()
+	}()
+
+	var timeout <-chan time.Time
+	if opts.QueueTimeout > 0 {
+		t := time.NewTimer(opts.QueueTimeout)
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	case <-r.Context().Done():
+		return r.Context().Err()
+	case <-timeout:
+		return fmt.Errorf("queue timeout: %w", ErrBulkheadFull)
+	}
+}
+
+func (b *bulkhead) release() {
+	<-b.slots
+}
@@ -0,0 +1,176 @@
+package httpc_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/kraciasty/httpc"
+)
+
+var bodyDoer = httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader("streamed body")),
+		Request:    r,
+	}, nil
+})
+
+func openBody(t *testing.T, c httpc.Doer, url string) (*http.Response, error) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, url, http.NoBody)
+	if err != nil {
+		t.Fatalf("Cannot create request: %v", err)
+	}
+
+	return c.Do(req)
+}
+
+func TestMaxInFlight(t *testing.T) {
+	synctest.Run(func() {
+		c := httpc.NewClient(bodyDoer, httpc.MaxInFlight(1, httpc.HostKey))
+		first, err := openBody(t, c, "http://a.local")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		other, err := openBody(t, c, "http://b.local")
+		if err != nil {
+			t.Fatalf("Expected other host to have its own limit: %v", err)
+		}
+		other.Body.Close()
+
+		done := make(chan error)
+		go func() {
+			resp, err := openBody(t, c, "http://a.local")
+			if err == nil {
+				resp.Body.Close()
+			}
+			done <- err
+		}()
+
+		synctest.Wait()
+		select {
+		case <-done:
+			t.Fatalf("Expected request to wait for the open body")
+		default:
+		}
+
+		// Reading the body to EOF frees the slot.
+		if _, err := io.ReadAll(first.Body); err != nil {
+			t.Fatalf("Cannot read body: %v", err)
+		}
+		if err := <-done; err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		first.Body.Close()
+	})
+}
+
+func TestBulkhead(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    httpc.BulkheadOptions
+		queued  int
+		ctx     func() (context.Context, context.CancelFunc)
+		wantErr error
+	}{
+		{
+			name:    "no queueing",
+			opts:    httpc.BulkheadOptions{Limit: 1, MaxQueue: -1},
+			wantErr: httpc.ErrBulkheadFull,
+		},
+		{
+			name:    "saturated queue",
+			opts:    httpc.BulkheadOptions{Limit: 1, MaxQueue: 2},
+			queued:  2,
+			wantErr: httpc.ErrBulkheadFull,
+		},
+		{
+			name:    "queue timeout",
+			opts:    httpc.BulkheadOptions{Limit: 1, QueueTimeout: time.Second},
+			wantErr: httpc.ErrBulkheadFull,
+		},
+		{
+			name: "context done",
+			opts: httpc.BulkheadOptions{Limit: 1},
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), time.Second)
+			},
+			wantErr: context.DeadlineExceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			synctest.Run(func() {
+				c := httpc.NewClient(bodyDoer, httpc.Bulkhead(tt.opts))
+				held, err := openBody(t, c, "http://localhost")
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+
+				for range tt.queued {
+					go func() {
+						resp, err := openBody(t, c, "http://localhost")
+						if err == nil {
+							resp.Body.Close()
+						}
+					}()
+				}
+				synctest.Wait()
+
+				ctx, cancel := context.Background(), context.CancelFunc(func() {})
+				if tt.ctx != nil {
+					ctx, cancel = tt.ctx()
+				}
+				defer cancel()
+
+				req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", http.NoBody)
+				if err != nil {
+					t.Fatalf("Cannot create request: %v", err)
+				}
+
+				_, err = c.Do(req)
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Expected error %v but got: %v", tt.wantErr, err)
+				}
+
+				held.Body.Close()
+			})
+		})
+	}
+}
+
+func TestBulkhead_panic(t *testing.T) {
+	calls := 0
+	doer := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+		if calls++; calls == 1 {
+			panic("boom")
+		}
+		return bodyDoer(r)
+	})
+	c := httpc.NewClient(doer, httpc.Recover(), httpc.Bulkhead(httpc.BulkheadOptions{Limit: 1, MaxQueue: -1}))
+
+	if _, err := openBody(t, c, "http://localhost"); !errors.Is(err, httpc.ErrPanicRecovered) {
+		t.Fatalf("Expected a recovered panic but got: %v", err)
+	}
+
+	resp, err := openBody(t, c, "http://localhost")
+	if err != nil {
+		t.Fatalf("Expected the slot to be released but got: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestBulkhead_noLimit(t *testing.T) {
+	if mw := httpc.Bulkhead(httpc.BulkheadOptions{}); mw != nil {
+		t.Errorf("Expected no middleware without a limit")
+	}
+}
@@ -0,0 +1,573 @@
+package httpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kraciasty/httpc/internal/httpbody"
+)
+
+// CacheStatusHeader is the conventional response header for reporting the
+// cache status, see [CacheOptions].
+const CacheStatusHeader = "X-Httpc-Cache"
+
+// Cache statuses reported in the status header.
+const (
+	cacheHit         = "HIT"
+	cacheMiss        = "MISS"
+	cacheRevalidated = "REVALIDATED"
+	cacheStale       = "STALE"
+)
+
+// CacheStore stores the responses cached by the [Cache] middleware.
+//
+// Implementations must be safe for concurrent use. Stores that fail to read
+// or write an entry should treat it as missing.
+type CacheStore interface {
+	// Get returns the value stored under the key.
+	Get(key string) ([]byte, bool)
+	// Set stores the value under the key.
+	Set(key string, value []byte)
+	// Delete removes the value stored under the key.
+	Delete(key string)
+}
+
+// CacheOptions configures the [Cache] middleware.
+type CacheOptions struct {
+	// Key returns the cache key for the request.
+	// Defaults to the request method and URL.
+	Key func(*http.Request) string
+
+	// StatusHeader is the response header set to HIT, MISS, REVALIDATED or
+	// STALE, depending on how the response was served. For example,
+	// [CacheStatusHeader]. The header is not set when empty.
+	StatusHeader string
+
+	// MaxEntrySize is the maximum size of a cached response body.
+	// Larger responses are not stored. Defaults to 10 MiB.
+	MaxEntrySize int64
+}
+
+func (o CacheOptions) withDefaults() CacheOptions {
+	if o.Key == nil {
+		o.Key = func(r *http.Request) string {
+			return r.Method + " " + r.URL.String()
+		}
+	}
+	if o.MaxEntrySize <= 0 {
+		o.MaxEntrySize = 10 << 20
+	}
+	return o
+}
+
+// Cache is a middleware that caches GET and HEAD responses in the store,
+// following the HTTP caching rules of RFC 9111 for a private cache.
+//
+// The freshness of the responses is computed from the Cache-Control max-age
+// directive, the Expires header or heuristically from the Last-Modified
+// header. Stale responses are revalidated with the If-None-Match and
+// If-Modified-Since headers and a 304 Not Modified response is turned into the
+// cached response. The no-store, no-cache, must-revalidate,
+// stale-while-revalidate and stale-if-error directives and the Vary header
+// are honored.
+//
+// Responses to requests with the Authorization header are stored only when
+// they have the public, s-maxage or must-revalidate directive, as required by
+// RFC 9111 Section 3.5, so that the credentials of one user do not serve
+// another user sharing the store.
+//
+// Successful requests with unsafe methods invalidate the cached responses of
+// the same URL. Requests with the Range or conditional headers, or with the
+// [SkipCache] option bypass the cache.
+//
+// Responses are stored once their body is fully read.
+func Cache(store CacheStore, opts CacheOptions) MiddlewareFunc {
+	c := &cache{
+		store: store,
+		opts:  opts.withDefaults(),
+	}
+
+	return func(next DoerFunc) DoerFunc {
+		return func(r *http.Request) (*http.Response, error) {
+			return c.do(next, r)
+		}
+	}
+}
+
+type cache struct {
+	store        CacheStore
+	opts         CacheOptions
+	revalidating sync.Map
+}
+
+func (c *cache) do(next DoerFunc, r *http.Request) (*http.Response, error) {
+	if skip, _ := SkipCache.Value(r.Context()); skip {
+		return next(r)
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		resp, err := next(r)
+		if err == nil && isUnsafeMethod(r.Method) && resp.StatusCode < 400 {
+			c.invalidate(r)
+		}
+		return resp, err
+	}
+
+	for _, h := range []string{"Range", "If-None-Match", "If-Modified-Since", "If-Match", "If-Unmodified-Since"} {
+		if r.Header.Get(h) != "" {
+			return next(r)
+		}
+	}
+
+	reqCC := parseCacheControl(r.Header)
+	if reqCC.has("no-store") {
+		return next(r)
+	}
+
+	key := c.opts.Key(r)
+	e, ok := c.load(key, r)
+	if !ok {
+		if reqCC.has("only-if-cached") {
+			return gatewayTimeout(r), nil
+		}
+		return c.fetch(next, r, key, cacheMiss)
+	}
+
+	now := time.Now()
+	resCC := parseCacheControl(e.Header)
+	age, lifetime := e.age(now), e.lifetime(resCC)
+	fresh := age < lifetime
+	if d, ok := reqCC.seconds("max-age"); ok && age > d {
+		fresh = false
+	}
+
+	noCache := reqCC.has("no-cache") || resCC.has("no-cache") ||
+		(r.Header.Get("Cache-Control") == "" && r.Header.Get("Pragma") == "no-cache")
+	if fresh && !noCache {
+		return c.serve(r, e, now, cacheHit), nil
+	}
+
+	if reqCC.has("only-if-cached") {
+		return gatewayTimeout(r), nil
+	}
+
+	staleness := age - lifetime
+	mayServeStale := !noCache && !resCC.has("must-revalidate")
+	if d, ok := resCC.seconds("stale-while-revalidate"); ok && mayServeStale && staleness <= d {
+		// The response is built before the entry is updated in the background.
+		resp := c.serve(r, e, now, cacheStale)
+		c.revalidateAsync(next, r, key, e)
+		return resp, nil
+	}
+
+	resp, err := c.revalidate(next, r, key, e)
+	if err != nil || resp.StatusCode >= http.StatusInternalServerError {
+		d, ok := resCC.seconds("stale-if-error")
+		if rd, rok := reqCC.seconds("stale-if-error"); rok {
+			d, ok = rd, true
+		}
+		if ok && mayServeStale && staleness <= d {
+			httpbody.Drain(resp)
+			return c.serve(r, e, time.Now(), cacheStale), nil
+		}
+	}
+
+	return resp, err
+}
+
+// fetch sends the request and stores the response if it is cacheable.
+func (c *cache) fetch(next DoerFunc, r *http.Request, key, status string) (*http.Response, error) {
+	reqTime := time.Now()
+	resp, err := next(r)
+	if err != nil {
+		return resp, err
+	}
+
+	c.maybeStore(r, resp, key, reqTime)
+	c.setStatus(resp, status)
+	return resp, nil
+}
+
+// revalidate sends a conditional request for the stale entry.
+func (c *cache) revalidate(next DoerFunc, r *http.Request, key string, e *cacheEntry) (*http.Response, error) {
+	etag, modified := e.Header.Get("ETag"), e.Header.Get("Last-Modified")
+	if etag == "" && modified == "" {
+		return c.fetch(next, r, key, cacheMiss)
+	}
+
+	req := r.Clone(r.Context())
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if modified != "" {
+		req.Header.Set("If-Modified-Since", modified)
+	}
+
+	reqTime := time.Now()
+	resp, err := next(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusNotModified {
+		c.maybeStore(r, resp, key, reqTime)
+		c.setStatus(resp, cacheMiss)
+		return resp, nil
+	}
+
+	httpbody.Drain(resp)
+	now := time.Now()
+	e.update(resp.Header, reqTime, now)
+	c.save(key, e)
+	return c.serve(r, e, now, cacheRevalidated), nil
+}
+
+// revalidateAsync revalidates the entry in the background, at most once at
+// a time for each key. The entry is owned by the revalidation afterwards.
+func (c *cache) revalidateAsync(next DoerFunc, r *http.Request, key string, e *cacheEntry) {
+	if _, loaded := c.revalidating.LoadOrStore(key, struct{}{}); loaded {
+		return
+	}
+
+	req := r.Clone(context.WithoutCancel(r.Context()))
+	go func() {
+		defer c.revalidating.Delete(key)
+
+		resp, err := c.revalidate(next, req, key, e)
+		if err != nil {
+			return
+		}
+
+		// The body has to be read for the response to be stored.
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+}
+
+// maybeStore stores the response once its body is read, if it is cacheable.
+func (c *cache) maybeStore(r *http.Request, resp *http.Response, key string, reqTime time.Time) {
+	resCC := parseCacheControl(resp.Header)
+	if !isCacheable(resp, resCC) {
+		return
+	}
+	if r.Header.Get("Authorization") != "" && !resCC.has("public") &&
+		!resCC.has("s-maxage") && !resCC.has("must-revalidate") {
+		return
+	}
+
+	vary := make(map[string][]string)
+	for _, v := range resp.Header.Values("Vary") {
+		for name := range strings.SplitSeq(v, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "*" {
+				return
+			}
+			if name != "" {
+				vary[name] = r.Header.Values(name)
+			}
+		}
+	}
+
+	e := &cacheEntry{
+		Status:       resp.Status,
+		StatusCode:   resp.StatusCode,
+		Proto:        resp.Proto,
+		ProtoMajor:   resp.ProtoMajor,
+		ProtoMinor:   resp.ProtoMinor,
+		Header:       resp.Header.Clone(),
+		Vary:         vary,
+		RequestTime:  reqTime,
+		ResponseTime: time.Now(),
+	}
+
+	if r.Method == http.MethodHead || resp.Body == nil || resp.Body == http.NoBody {
+		c.save(key, e)
+		return
+	}
+
+	if resp.ContentLength > c.opts.MaxEntrySize {
+		return
+	}
+
+	resp.Body = &cachingBody{
+		ReadCloser: resp.Body,
+		limit:      c.opts.MaxEntrySize,
+		store: func(body []byte) {
+			e.Body = body
+			c.save(key, e)
+		},
+	}
+}
+
+func (c *cache) load(key string, r *http.Request) (*cacheEntry, bool) {
+	data, ok := c.store.Get(key)
+	if !ok {
+		return nil, false
+	}
+
+	var e cacheEntry
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&e); err != nil {
+		c.store.Delete(key)
+		return nil, false
+	}
+
+	for name, values := range e.Vary {
+		if !slices.Equal(r.Header.Values(name), values) {
+			return nil, false
+		}
+	}
+
+	return &e, true
+}
+
+func (c *cache) save(key string, e *cacheEntry) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(e); err != nil {
+		return
+	}
+
+	c.store.Set(key, buf.Bytes())
+}
+
+func (c *cache) invalidate(r *http.Request) {
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		req := r.Clone(r.Context())
+		req.Method = method
+		c.store.Delete(c.opts.Key(req))
+	}
+}
+
+// serve builds a response from the cached entry.
+func (c *cache) serve(r *http.Request, e *cacheEntry, now time.Time, status string) *http.Response {
+	resp := &http.Response{
+		Status:        e.Status,
+		StatusCode:    e.StatusCode,
+		Proto:         e.Proto,
+		ProtoMajor:    e.ProtoMajor,
+		ProtoMinor:    e.ProtoMinor,
+		Header:        e.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       r,
+	}
+	if r.Method == http.MethodHead {
+		resp.Body = http.NoBody
+		resp.ContentLength = -1
+		if n, err := strconv.ParseInt(e.Header.Get("Content-Length"), 10, 64); err == nil {
+			resp.ContentLength = n
+		}
+	}
+
+	resp.Header.Set("Age", strconv.FormatInt(int64(e.age(now)/time.Second), 10))
+	c.setStatus(resp, status)
+	return resp
+}
+
+func (c *cache) setStatus(resp *http.Response, status string) {
+	if c.opts.StatusHeader != "" {
+		resp.Header.Set(c.opts.StatusHeader, status)
+	}
+}
+
+// cacheEntry is a stored response.
+type cacheEntry struct {
+	Status       string
+	StatusCode   int
+	Proto        string
+	ProtoMajor   int
+	ProtoMinor   int
+	Header       http.Header
+	Body         []byte
+	Vary         map[string][]string // request header values selected by Vary
+	RequestTime  time.Time
+	ResponseTime time.Time
+}
+
+// date returns the Date header or the time the response was received.
+func (e *cacheEntry) date() time.Time {
+	if t, err := http.ParseTime(e.Header.Get("Date")); err == nil {
+		return t
+	}
+	return e.ResponseTime
+}
+
+// age computes the current age of the response, see RFC 9111 section 4.2.3.
+func (e *cacheEntry) age(now time.Time) time.Duration {
+	var ageValue time.Duration
+	if secs, err := strconv.ParseInt(e.Header.Get("Age"), 10, 64); err == nil && secs > 0 {
+		ageValue = time.Duration(secs) * time.Second
+	}
+
+	apparentAge := max(e.ResponseTime.Sub(e.date()), 0)
+	correctedAge := ageValue + e.ResponseTime.Sub(e.RequestTime)
+	residentTime := now.Sub(e.ResponseTime)
+	return max(apparentAge, correctedAge) + residentTime
+}
+
+// lifetime computes the freshness lifetime of the response, see RFC 9111
+// section 4.2.1. The s-maxage directive is ignored by private caches.
+func (e *cacheEntry) lifetime(cc cacheControl) time.Duration {
+	if d, ok := cc.seconds("max-age"); ok {
+		return d
+	}
+
+	if v := e.Header.Get("Expires"); v != "" {
+		t, err := http.ParseTime(v)
+		if err != nil {
+			return 0
+		}
+		return max(t.Sub(e.date()), 0)
+	}
+
+	if !isHeuristicallyCacheable(e.StatusCode) {
+		return 0
+	}
+
+	modified, err := http.ParseTime(e.Header.Get("Last-Modified"))
+	if err != nil {
+		return 0
+	}
+
+	// A typical heuristic is 10% of the time since the last modification.
+	return max(e.date().Sub(modified)/10, 0)
+}
+
+// update merges the headers of a 304 Not Modified response.
+func (e *cacheEntry) update(h http.Header, reqTime, respTime time.Time) {
+	for k, v := range h {
+		switch k {
+		case "Content-Length", "Content-Encoding", "Transfer-Encoding":
+			continue
+		}
+		e.Header[k] = slices.Clone(v)
+	}
+	e.RequestTime = reqTime
+	e.ResponseTime = respTime
+}
+
+func isCacheable(resp *http.Response, cc cacheControl) bool {
+	if cc.has("no-store") {
+		return false
+	}
+
+	if _, ok := cc.seconds("max-age"); ok {
+		return true
+	}
+
+	return resp.Header.Get("Expires") != "" || cc.has("public") ||
+		cc.has("private") || isHeuristicallyCacheable(resp.StatusCode)
+}
+
+// isHeuristicallyCacheable reports whether the status code is cacheable by
+// default, see RFC 9110 section 15.1. Partial content is not supported.
+func isHeuristicallyCacheable(code int) bool {
+	switch code {
+	case http.StatusOK,
+		http.StatusNonAuthoritativeInfo,
+		http.StatusNoContent,
+		http.StatusMultipleChoices,
+		http.StatusMovedPermanently,
+		http.StatusPermanentRedirect,
+		http.StatusNotFound,
+		http.StatusMethodNotAllowed,
+		http.StatusGone,
+		http.StatusRequestURITooLong,
+		http.StatusNotImplemented:
+		return true
+	default:
+		return false
+	}
+}
+
+func isUnsafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return false
+	default:
+		return true
+	}
+}
+
+func gatewayTimeout(r *http.Request) *http.Response {
+	return &http.Response{
+		Status:     "504 Gateway Timeout",
+		StatusCode: http.StatusGatewayTimeout,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Body:       http.NoBody,
+		Request:    r,
+	}
+}
+
+// cacheControl holds the parsed Cache-Control directives.
+type cacheControl map[string]string
+
+func parseCacheControl(h http.Header) cacheControl {
+	cc := make(cacheControl)
+	for _, v := range h.Values("Cache-Control") {
+		for directive := range strings.SplitSeq(v, ",") {
+			k, val, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if k == "" {
+				continue
+			}
+			cc[strings.ToLower(k)] = strings.Trim(val, `"`)
+		}
+	}
+	return cc
+}
+
+func (cc cacheControl) has(directive string) bool {
+	_, ok := cc[directive]
+	return ok
+}
+
+func (cc cacheControl) seconds(directive string) (time.Duration, bool) {
+	v, ok := cc[directive]
+	if !ok {
+		return 0, false
+	}
+
+	secs, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || secs < 0 {
+		return 0, false
+	}
+	return time.Duration(secs) * time.Second, true
+}
+
+// cachingBody buffers the body while it is read and stores it on EOF.
+type cachingBody struct {
+	io.ReadCloser
+	buf      bytes.Buffer
+	limit    int64
+	overflow bool
+	stored   bool
+	store    func([]byte)
+}
+
+func (b *cachingBody) Read(p []byte) (n int, err error) {
+	n, err = b.ReadCloser.Read(p)
+	if !b.overflow {
+		b.buf.Write(p[:n])
+		if int64(b.buf.Len()) > b.limit {
+			b.overflow = true
+			b.buf = bytes.Buffer{}
+		}
+	}
+
+	if errors.Is(err, io.EOF) && !b.overflow && !b.stored {
+		b.stored = true
+		b.store(bytes.Clone(b.buf.Bytes()))
+	}
+	return n, err
+}
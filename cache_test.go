@@ -0,0 +1,465 @@
+package httpc_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/kraciasty/httpc"
+)
+
+// An origin doer that counts the requests and replies with the handler.
+type origin struct {
+	calls   int
+	handler func(r *http.Request) (*http.Response, error)
+}
+
+func (o *origin) Do(r *http.Request) (*http.Response, error) {
+	o.calls++
+	return o.handler(r)
+}
+
+func originResponse(r *http.Request, code int, header http.Header, body string) *http.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+	if header.Get("Date") == "" {
+		header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       r,
+	}
+}
+
+// Performs a GET request and returns the cache status and the body.
+func cachedGet(t *testing.T, c httpc.Doer, header http.Header) (string, string, error) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, "http://localhost/resource", http.NoBody)
+	if err != nil {
+		t.Fatalf("Cannot create request: %v", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Cannot read body: %v", err)
+	}
+	return resp.Header.Get(httpc.CacheStatusHeader), string(body), nil
+}
+
+func newCacheClient(o *origin) httpc.Doer {
+	return httpc.NewClient(o, httpc.Cache(httpc.NewMemoryCache(0), httpc.CacheOptions{
+		StatusHeader: httpc.CacheStatusHeader,
+	}))
+}
+
+func TestCache(t *testing.T) {
+	type step struct {
+		wait       time.Duration
+		header     http.Header
+		wantStatus string
+		wantBody   string
+		wantErr    bool
+		wantCalls  int
+	}
+
+	tests := []struct {
+		name    string
+		handler func(r *http.Request) (*http.Response, error)
+		steps   []step
+	}{
+		{
+			name: "max-age and revalidation",
+			handler: func(r *http.Request) (*http.Response, error) {
+				if r.Header.Get("If-None-Match") == `"v1"` {
+					return originResponse(r, http.StatusNotModified, nil, ""), nil
+				}
+				return originResponse(r, http.StatusOK, http.Header{
+					"Cache-Control": {"max-age=60"},
+					"Etag":          {`"v1"`},
+				}, "cached"), nil
+			},
+			steps: []step{
+				{wantStatus: "MISS", wantBody: "cached", wantCalls: 1},
+				{wait: 30 * time.Second, wantStatus: "HIT", wantBody: "cached", wantCalls: 1},
+				{wait: 31 * time.Second, wantStatus: "REVALIDATED", wantBody: "cached", wantCalls: 2},
+				{wait: 59 * time.Second, wantStatus: "HIT", wantBody: "cached", wantCalls: 2},
+			},
+		},
+		{
+			name: "revalidation with a new response",
+			handler: func(r *http.Request) (*http.Response, error) {
+				if r.Header.Get("If-Modified-Since") != "" {
+					return originResponse(r, http.StatusOK, nil, "updated"), nil
+				}
+				return originResponse(r, http.StatusOK, http.Header{
+					"Cache-Control": {"max-age=60"},
+					"Last-Modified": {"Sat, 01 Jan 1999 00:00:00 GMT"},
+				}, "cached"), nil
+			},
+			steps: []step{
+				{wantStatus: "MISS", wantBody: "cached", wantCalls: 1},
+				{wait: time.Hour, wantStatus: "MISS", wantBody: "updated", wantCalls: 2},
+			},
+		},
+		{
+			name: "no-store",
+			handler: func(r *http.Request) (*http.Response, error) {
+				return originResponse(r, http.StatusOK, http.Header{
+					"Cache-Control": {"no-store, max-age=60"},
+				}, "private"), nil
+			},
+			steps: []step{
+				{wantStatus: "MISS", wantBody: "private", wantCalls: 1},
+				{wantStatus: "MISS", wantBody: "private", wantCalls: 2},
+			},
+		},
+		{
+			name: "request no-cache",
+			handler: func(r *http.Request) (*http.Response, error) {
+				return originResponse(r, http.StatusOK, http.Header{
+					"Cache-Control": {"max-age=60"},
+				}, "cached"), nil
+			},
+			steps: []step{
+				{wantStatus: "MISS", wantBody: "cached", wantCalls: 1},
+				{header: http.Header{"Cache-Control": {"no-cache"}}, wantStatus: "MISS", wantBody: "cached", wantCalls: 2},
+				{header: http.Header{"Pragma": {"no-cache"}}, wantStatus: "MISS", wantBody: "cached", wantCalls: 3},
+				{wantStatus: "HIT", wantBody: "cached", wantCalls: 3},
+			},
+		},
+		{
+			name: "expires",
+			handler: func(r *http.Request) (*http.Response, error) {
+				return originResponse(r, http.StatusOK, http.Header{
+					"Expires": {time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)},
+				}, "cached"), nil
+			},
+			steps: []step{
+				{wantStatus: "MISS", wantBody: "cached", wantCalls: 1},
+				{wait: 59 * time.Second, wantStatus: "HIT", wantBody: "cached", wantCalls: 1},
+				{wait: time.Second, wantStatus: "MISS", wantBody: "cached", wantCalls: 2},
+			},
+		},
+		{
+			name: "heuristic freshness",
+			handler: func(r *http.Request) (*http.Response, error) {
+				return originResponse(r, http.StatusOK, http.Header{
+					"Last-Modified": {time.Now().Add(-100 * time.Minute).UTC().Format(http.TimeFormat)},
+				}, "cached"), nil
+			},
+			steps: []step{
+				{wantStatus: "MISS", wantBody: "cached", wantCalls: 1},
+				{wait: 9 * time.Minute, wantStatus: "HIT", wantBody: "cached", wantCalls: 1},
+				{wait: time.Minute, wantStatus: "MISS", wantBody: "cached", wantCalls: 2},
+			},
+		},
+		{
+			name: "vary",
+			handler: func(r *http.Request) (*http.Response, error) {
+				return originResponse(r, http.StatusOK, http.Header{
+					"Cache-Control": {"max-age=60"},
+					"Vary":          {"Accept-Language"},
+				}, r.Header.Get("Accept-Language")), nil
+			},
+			steps: []step{
+				{header: http.Header{"Accept-Language": {"en"}}, wantStatus: "MISS", wantBody: "en", wantCalls: 1},
+				{header: http.Header{"Accept-Language": {"en"}}, wantStatus: "HIT", wantBody: "en", wantCalls: 1},
+				{header: http.Header{"Accept-Language": {"pl"}}, wantStatus: "MISS", wantBody: "pl", wantCalls: 2},
+			},
+		},
+		{
+			name: "authorization",
+			handler: func(r *http.Request) (*http.Response, error) {
+				return originResponse(r, http.StatusOK, http.Header{
+					"Cache-Control": {"max-age=60"},
+				}, r.Header.Get("Authorization")), nil
+			},
+			steps: []step{
+				{header: http.Header{"Authorization": {"alice"}}, wantStatus: "MISS", wantBody: "alice", wantCalls: 1},
+				{header: http.Header{"Authorization": {"bob"}}, wantStatus: "MISS", wantBody: "bob", wantCalls: 2},
+				{wantStatus: "MISS", wantCalls: 3},
+			},
+		},
+		{
+			name: "authorization with public",
+			handler: func(r *http.Request) (*http.Response, error) {
+				return originResponse(r, http.StatusOK, http.Header{
+					"Cache-Control": {"public, max-age=60"},
+				}, "shared"), nil
+			},
+			steps: []step{
+				{header: http.Header{"Authorization": {"alice"}}, wantStatus: "MISS", wantBody: "shared", wantCalls: 1},
+				{header: http.Header{"Authorization": {"bob"}}, wantStatus: "HIT", wantBody: "shared", wantCalls: 1},
+				{wantStatus: "HIT", wantBody: "shared", wantCalls: 1},
+			},
+		},
+		{
+			name: "stale-if-error",
+			handler: func(r *http.Request) (*http.Response, error) {
+				if r.Header.Get("If-None-Match") != "" {
+					return originResponse(r, http.StatusServiceUnavailable, nil, "down"), nil
+				}
+				return originResponse(r, http.StatusOK, http.Header{
+					"Cache-Control": {"max-age=60, stale-if-error=60"},
+					"Etag":          {`"v1"`},
+				}, "cached"), nil
+			},
+			steps: []step{
+				{wantStatus: "MISS", wantBody: "cached", wantCalls: 1},
+				{wait: 90 * time.Second, wantStatus: "STALE", wantBody: "cached", wantCalls: 2},
+				{wait: time.Minute, wantStatus: "MISS", wantBody: "down", wantCalls: 3},
+			},
+		},
+		{
+			name: "must-revalidate",
+			handler: func(r *http.Request) (*http.Response, error) {
+				if r.Header.Get("If-None-Match") != "" {
+					return nil, errors.New("origin down")
+				}
+				return originResponse(r, http.StatusOK, http.Header{
+					"Cache-Control": {"max-age=60, stale-if-error=60, must-revalidate"},
+					"Etag":          {`"v1"`},
+				}, "cached"), nil
+			},
+			steps: []step{
+				{wantStatus: "MISS", wantBody: "cached", wantCalls: 1},
+				{wait: 90 * time.Second, wantErr: true, wantCalls: 2},
+			},
+		},
+		{
+			name: "only-if-cached",
+			handler: func(r *http.Request) (*http.Response, error) {
+				return originResponse(r, http.StatusOK, nil, "uncacheable"), nil
+			},
+			steps: []step{
+				{header: http.Header{"Cache-Control": {"only-if-cached"}}, wantCalls: 0},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			synctest.Run(func() {
+				o := &origin{handler: tt.handler}
+				c := newCacheClient(o)
+				for i, s := range tt.steps {
+					time.Sleep(s.wait)
+					status, body, err := cachedGet(t, c, s.header)
+					if (err != nil) != s.wantErr {
+						t.Fatalf("Step %d: unexpected error: %v", i, err)
+					}
+					if status != s.wantStatus {
+						t.Errorf("Step %d: expected cache status %q but got %q", i, s.wantStatus, status)
+					}
+					if body != s.wantBody {
+						t.Errorf("Step %d: expected body %q but got %q", i, s.wantBody, body)
+					}
+					if o.calls != s.wantCalls {
+						t.Errorf("Step %d: expected %d origin calls but got %d", i, s.wantCalls, o.calls)
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestCache_staleWhileRevalidate(t *testing.T) {
+	synctest.Run(func() {
+		version := "v1"
+		o := &origin{handler: func(r *http.Request) (*http.Response, error) {
+			return originResponse(r, http.StatusOK, http.Header{
+				"Cache-Control": {"max-age=60, stale-while-revalidate=60"},
+			}, version), nil
+		}}
+		c := newCacheClient(o)
+
+		if _, _, err := cachedGet(t, c, nil); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		time.Sleep(90 * time.Second)
+		version = "v2"
+		status, body, err := cachedGet(t, c, nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if status != "STALE" || body != "v1" {
+			t.Errorf("Expected stale v1 response but got %s %s", status, body)
+		}
+
+		synctest.Wait()
+		status, body, err = cachedGet(t, c, nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if status != "HIT" || body != "v2" {
+			t.Errorf("Expected revalidated v2 response but got %s %s", status, body)
+		}
+		if o.calls != 2 {
+			t.Errorf("Expected 2 origin calls but got %d", o.calls)
+		}
+	})
+}
+
+func TestCache_staleWhileRevalidateNotModified(t *testing.T) {
+	synctest.Run(func() {
+		o := &origin{handler: func(r *http.Request) (*http.Response, error) {
+			header := http.Header{
+				"Cache-Control": {"max-age=60, stale-while-revalidate=60"},
+				"Etag":          {`"v1"`},
+			}
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				return originResponse(r, http.StatusNotModified, header, ""), nil
+			}
+			return originResponse(r, http.StatusOK, header, "v1"), nil
+		}}
+		c := newCacheClient(o)
+
+		if _, _, err := cachedGet(t, c, nil); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		time.Sleep(90 * time.Second)
+		status, body, err := cachedGet(t, c, nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if status != "STALE" || body != "v1" {
+			t.Errorf("Expected stale v1 response but got %s %s", status, body)
+		}
+
+		synctest.Wait()
+		status, body, err = cachedGet(t, c, nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if status != "HIT" || body != "v1" {
+			t.Errorf("Expected revalidated v1 response but got %s %s", status, body)
+		}
+		if o.calls != 2 {
+			t.Errorf("Expected 2 origin calls but got %d", o.calls)
+		}
+	})
+}
+
+func TestCache_invalidation(t *testing.T) {
+	o := &origin{handler: func(r *http.Request) (*http.Response, error) {
+		return originResponse(r, http.StatusOK, http.Header{
+			"Cache-Control": {"max-age=60"},
+		}, "cached"), nil
+	}}
+	c := newCacheClient(o)
+
+	if _, _, err := cachedGet(t, c, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, "http://localhost/resource", strings.NewReader("update"))
+	if err != nil {
+		t.Fatalf("Cannot create request: %v", err)
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if status, _, _ := cachedGet(t, c, nil); status != "MISS" {
+		t.Errorf("Expected invalidated entry but got %q", status)
+	}
+	if o.calls != 3 {
+		t.Errorf("Expected 3 origin calls but got %d", o.calls)
+	}
+}
+
+func TestCache_partialRead(t *testing.T) {
+	o := &origin{handler: func(r *http.Request) (*http.Response, error) {
+		return originResponse(r, http.StatusOK, http.Header{
+			"Cache-Control": {"max-age=60"},
+		}, "cached"), nil
+	}}
+	c := newCacheClient(o)
+
+	req, err := http.NewRequest(http.MethodGet, "http://localhost/resource", http.NoBody)
+	if err != nil {
+		t.Fatalf("Cannot create request: %v", err)
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if status, _, _ := cachedGet(t, c, nil); status != "MISS" {
+		t.Errorf("Expected unread response not to be stored but got %q", status)
+	}
+}
+
+func TestMemoryCache(t *testing.T) {
+	c := httpc.NewMemoryCache(10)
+	c.Set("a", []byte("aaaa"))
+	c.Set("b", []byte("bbbb"))
+	if _, ok := c.Get("a"); !ok {
+		t.Fatalf("Expected entry a")
+	}
+
+	c.Set("c", []byte("cccc"))
+	if _, ok := c.Get("b"); ok {
+		t.Errorf("Expected least recently used entry b to be evicted")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Errorf("Expected entry a to be kept")
+	}
+
+	c.Set("d", []byte("too large value"))
+	if _, ok := c.Get("d"); ok {
+		t.Errorf("Expected too large entry to be skipped")
+	}
+
+	c.Delete("a")
+	if got := c.Len(); got != 1 {
+		t.Errorf("Expected 1 entry but got %d", got)
+	}
+}
+
+func TestFileCache(t *testing.T) {
+	c := httpc.NewFileCache(t.TempDir())
+	if _, ok := c.Get("key"); ok {
+		t.Fatalf("Expected no entry")
+	}
+
+	c.Set("key", []byte("value"))
+	got, ok := c.Get("key")
+	if !ok || string(got) != "value" {
+		t.Errorf("Expected stored value but got %q", got)
+	}
+
+	c.Delete("key")
+	if _, ok := c.Get("key"); ok {
+		t.Errorf("Expected deleted entry")
+	}
+}
@@ -0,0 +1,147 @@
+package httpc
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// MemoryCache is an in-memory [CacheStore] that evicts the least recently
+// used entries when its size limit is exceeded.
+type MemoryCache struct {
+	maxBytes int64
+
+	mu    sync.Mutex
+	size  int64
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type memoryCacheItem struct {
+	key   string
+	value []byte
+}
+
+// NewMemoryCache returns a new [MemoryCache] that holds up to maxBytes of
+// values. A limit below or equal to zero means no limit.
+func NewMemoryCache(maxBytes int64) *MemoryCache {
+	return &MemoryCache{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Get implements [CacheStore].
+func (c *MemoryCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	c.ll.MoveToFront(el)
+	return el.Value.(*memoryCacheItem).value, true
+}
+
+// Set implements [CacheStore].
+func (c *MemoryCache) Set(key string, value []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.remove(key)
+	if c.maxBytes > 0 && int64(len(value)) > c.maxBytes {
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&memoryCacheItem{key: key, value: value})
+	c.size += int64(len(value))
+	for c.maxBytes > 0 && c.size > c.maxBytes {
+		c.remove(c.ll.Back().Value.(*memoryCacheItem).key)
+	}
+}
+
+// Delete implements [CacheStore].
+func (c *MemoryCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.remove(key)
+}
+
+// Len returns the number of cached entries.
+func (c *MemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.ll.Len()
+}
+
+func (c *MemoryCache) remove(key string) {
+	el, ok := c.items[key]
+	if !ok {
+		return
+	}
+
+	c.ll.Remove(el)
+	delete(c.items, key)
+	c.size -= int64(len(el.Value.(*memoryCacheItem).value))
+}
+
+// FileCache is a [CacheStore] that keeps each entry in a file in a directory.
+//
+// Entries are written atomically, so the directory may be shared by multiple
+// processes. Errors are treated as missing entries.
+type FileCache struct {
+	dir string
+}
+
+// NewFileCache returns a new [FileCache] storing the entries in dir.
+// The directory is created if it does not exist.
+func NewFileCache(dir string) *FileCache {
+	_ = os.MkdirAll(dir, 0o755)
+	return &FileCache{dir: dir}
+}
+
+// Get implements [CacheStore].
+func (c *FileCache) Get(key string) ([]byte, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// Set implements [CacheStore].
+func (c *FileCache) Set(key string, value []byte) {
+	f, err := os.CreateTemp(c.dir, "tmp-*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(value)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return
+	}
+
+	_ = os.Rename(f.Name(), c.path(key))
+}
+
+// Delete implements [CacheStore].
+func (c *FileCache) Delete(key string) {
+	_ = os.Remove(c.path(key))
+}
+
+func (c *FileCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+}
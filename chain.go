@@ -0,0 +1,349 @@
+package httpc
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// Named returns a middleware that behaves like mw and is identified by the
+// name in the chain descriptions, see [Client.Middlewares].
+//
+// A nil middleware is kept in the chain as a named placeholder that does
+// nothing.
+func Named(name string, mw MiddlewareFunc) MiddlewareFunc {
+	n := &named{name: name, mw: mw}
+	return n.middleware
+}
+
+// named is a middleware with a name.
+type named struct {
+	name string
+	mw   MiddlewareFunc
+}
+
+// namedPC is the code pointer shared by all the middlewares returned by
+// [Named], so that they can be told apart from other middlewares.
+var namedPC = reflect.ValueOf(MiddlewareFunc((*named)(nil).middleware)).Pointer()
+
+func (n *named) middleware(next DoerFunc) DoerFunc {
+	if next == nil {
+		// Probed by namedOf, see below.
+		return n.probe
+	}
+	if n.mw == nil {
+		return next
+	}
+	return n.mw(next)
+}
+
+func (n *named) probe(*http.Request) (*http.Response, error) {
+	return nil, namedProbe{n}
+}
+
+// namedProbe carries the named middleware out of a probe.
+type namedProbe struct{ n *named }
+
+func (namedProbe) Error() string { return "httpc: named middleware probe" }
+
+// namedOf returns the named middleware behind mw, if mw was created with
+// [Named]. Functions do not carry any data, so the named middleware is probed
+// with a nil next doer. Other middlewares are never called.
+func namedOf(mw MiddlewareFunc) (*named, bool) {
+	if mw == nil || reflect.ValueOf(mw).Pointer() != namedPC {
+		return nil, false
+	}
+
+	_, err := mw(nil)(nil)
+	p, ok := err.(namedProbe)
+	return p.n, ok
+}
+
+// namedIn returns the named middlewares behind the middlewares, with nil for
+// the unnamed ones. It is called once when a [Client] or a [RoundTripper] is
+// created and the result is kept next to its middlewares, so that the chain is
+// described and edited without probing the middlewares again.
+func namedIn(mws []MiddlewareFunc) []*named {
+	ns := make([]*named, len(mws))
+	for i, mw := range mws {
+		ns[i], _ = namedOf(mw)
+	}
+	return ns
+}
+
+// MiddlewareInfo describes a middleware in a chain.
+type MiddlewareInfo struct {
+	// Name is the name given with [Named], empty for unnamed middlewares.
+	Name string `json:"name,omitempty"`
+
+	// Func is the fully qualified name of the function that declared the
+	// middleware, e.g. "github.com/kraciasty/httpc.Retry". Empty for named
+	// placeholders.
+	Func string `json:"func,omitempty"`
+}
+
+// String returns the name of the middleware or its function name when
+// unnamed.
+func (i MiddlewareInfo) String() string {
+	if i.Name != "" {
+		return i.Name
+	}
+	return i.Func
+}
+
+// describeMiddlewares returns the descriptors of the middlewares, skipping the
+// nil ones that are not applied.
+func describeMiddlewares(mws []MiddlewareFunc, ns []*named) []MiddlewareInfo {
+	infos := make([]MiddlewareInfo, 0, len(mws))
+	for i, mw := range mws {
+		if mw == nil {
+			continue
+		}
+
+		var info MiddlewareInfo
+		if n := ns[i]; n != nil {
+			info.Name = n.name
+			mw = n.mw
+		}
+		if mw != nil {
+			info.Func = funcName(mw)
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// funcName returns the name of the function that declared f, without the
+// suffixes of the closures and method values.
+func funcName(f any) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(f).Pointer())
+	if fn == nil {
+		return ""
+	}
+
+	name := strings.TrimSuffix(fn.Name(), "-fm")
+	for {
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 || !isClosureSuffix(name[i+1:]) {
+			return name
+		}
+		name = name[:i]
+	}
+}
+
+// isClosureSuffix reports whether s is a closure name suffix, e.g. "func1" or
+// "1" for closures in inlined functions.
+func isClosureSuffix(s string) bool {
+	s = strings.TrimPrefix(s, "func")
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Middlewares returns the descriptors of the client middlewares in the order
+// of execution.
+func (c *Client) Middlewares() []MiddlewareInfo {
+	return describeMiddlewares(c.middlewares, c.named)
+}
+
+// Middlewares returns the descriptors of the round tripper middlewares in the
+// order of execution.
+func (t *RoundTripper) Middlewares() []MiddlewareInfo {
+	return describeMiddlewares(t.middlewares, t.named)
+}
+
+// ChainDescription describes the full middleware chain of a [Client] or a
+// [RoundTripper], from the outermost layer to the base doer.
+//
+// It renders as text with String and as JSON with [encoding/json], e.g. for
+// debug endpoints.
+type ChainDescription struct {
+	// Layers are the middleware layers in the order of execution.
+	Layers []LayerDescription `json:"layers"`
+
+	// Base is the type of the innermost doer or round tripper.
+	Base string `json:"base"`
+}
+
+// LayerDescription describes the middlewares of a single [Client] or
+// [RoundTripper].
+type LayerDescription struct {
+	// Kind is "client" for a [Client] and "transport" for a [RoundTripper].
+	Kind string `json:"kind"`
+
+	// Middlewares are the layer middlewares in the order of execution.
+	Middlewares []MiddlewareInfo `json:"middlewares"`
+}
+
+// String returns a text representation of the chain with a line per layer.
+func (d ChainDescription) String() string {
+	var b strings.Builder
+	for _, l := range d.Layers {
+		names := make([]string, len(l.Middlewares))
+		for i, mw := range l.Middlewares {
+			names[i] = mw.String()
+		}
+		fmt.Fprintf(&b, "%s: %s\n", l.Kind, strings.Join(names, ", "))
+	}
+	fmt.Fprintf(&b, "base: %s", d.Base)
+	return b.String()
+}
+
+// Describe returns the description of the full client chain.
+//
+// The chain is followed through the nested clients and round trippers.
+// An [http.Client] is described by its transport.
+func (c *Client) Describe() ChainDescription {
+	return describeChain(c)
+}
+
+// Describe returns the description of the full round tripper chain.
+//
+// The chain is followed through the nested round trippers.
+func (t *RoundTripper) Describe() ChainDescription {
+	return describeChain(t)
+}
+
+func describeChain(base any) ChainDescription {
+	var d ChainDescription
+	for {
+		switch b := base.(type) {
+		case *Client:
+			d.Layers = append(d.Layers, LayerDescription{Kind: "client", Middlewares: b.Middlewares()})
+			base = b.base
+			continue
+		case *RoundTripper:
+			d.Layers = append(d.Layers, LayerDescription{Kind: "transport", Middlewares: b.Middlewares()})
+			base = b.base
+			continue
+		case *http.Client:
+			base = b.Transport
+			if base == nil {
+				base = http.DefaultTransport
+			}
+			continue
+		}
+
+		d.Base = fmt.Sprintf("%T", base)
+		return d
+	}
+}
+
+// hasName reports whether the named middleware has the name.
+func hasName(name string) func(*named) bool {
+	return func(n *named) bool {
+		return n != nil && n.name == name
+	}
+}
+
+// withoutNamed returns copies of the middlewares and the named middlewares
+// without the ones with the name.
+func withoutNamed(mws []MiddlewareFunc, ns []*named, name string) ([]MiddlewareFunc, []*named) {
+	var (
+		keptMws = make([]MiddlewareFunc, 0, len(mws))
+		keptNs  = make([]*named, 0, len(ns))
+	)
+	for i, n := range ns {
+		if !hasName(name)(n) {
+			keptMws = append(keptMws, mws[i])
+			keptNs = append(keptNs, n)
+		}
+	}
+	return keptMws, keptNs
+}
+
+// replaceNamed returns copies of the middlewares and the named middlewares
+// with the ones with the name replaced by mw under the same name.
+func replaceNamed(mws []MiddlewareFunc, ns []*named, name string, mw MiddlewareFunc) ([]MiddlewareFunc, []*named) {
+	mws, ns = slices.Clone(mws), slices.Clone(ns)
+	for i, n := range ns {
+		if hasName(name)(n) {
+			ns[i] = &named{name: name, mw: mw}
+			mws[i] = ns[i].middleware
+		}
+	}
+	return mws, ns
+}
+
+// insertNamed returns copies of the middlewares and the named middlewares with
+// the inserted ones at the offset from the first middleware with the name. The
+// copies are unchanged when there is no such middleware.
+func insertNamed(mws []MiddlewareFunc, ns []*named, name string, offset int, inserted []MiddlewareFunc) ([]MiddlewareFunc, []*named) {
+	i := slices.IndexFunc(ns, hasName(name))
+	if i < 0 {
+		return slices.Clone(mws), slices.Clone(ns)
+	}
+	return slices.Insert(slices.Clone(mws), i+offset, inserted...),
+		slices.Insert(slices.Clone(ns), i+offset, namedIn(inserted)...)
+}
+
+// Without creates a new client without the middlewares with the name, see
+// [Named].
+func (c *Client) Without(name string) *Client {
+	edited, ns := withoutNamed(c.middlewares, c.named, name)
+	return newClient(c.base, edited, ns)
+}
+
+// Replace creates a new client with the middlewares with the name replaced by
+// mw, keeping the name. The new client has the same middlewares when none
+// has the name.
+func (c *Client) Replace(name string, mw MiddlewareFunc) *Client {
+	edited, ns := replaceNamed(c.middlewares, c.named, name, mw)
+	return newClient(c.base, edited, ns)
+}
+
+// InsertBefore creates a new client with the middlewares inserted before the
+// first middleware with the name. The new client has the same middlewares
+// when none has the name.
+func (c *Client) InsertBefore(name string, mws ...MiddlewareFunc) *Client {
+	edited, ns := insertNamed(c.middlewares, c.named, name, 0, mws)
+	return newClient(c.base, edited, ns)
+}
+
+// InsertAfter creates a new client with the middlewares inserted after the
+// first middleware with the name. The new client has the same middlewares
+// when none has the name.
+func (c *Client) InsertAfter(name string, mws ...MiddlewareFunc) *Client {
+	edited, ns := insertNamed(c.middlewares, c.named, name, 1, mws)
+	return newClient(c.base, edited, ns)
+}
+
+// Without creates a new [RoundTripper] without the middlewares with the name,
+// see [Named].
+func (t *RoundTripper) Without(name string) *RoundTripper {
+	edited, ns := withoutNamed(t.middlewares, t.named, name)
+	return newRoundTripper(t.base, edited, ns)
+}
+
+// Replace creates a new [RoundTripper] with the middlewares with the name
+// replaced by mw, keeping the name. The new [RoundTripper] has the same
+// middlewares when none has the name.
+func (t *RoundTripper) Replace(name string, mw MiddlewareFunc) *RoundTripper {
+	edited, ns := replaceNamed(t.middlewares, t.named, name, mw)
+	return newRoundTripper(t.base, edited, ns)
+}
+
+// InsertBefore creates a new [RoundTripper] with the middlewares inserted
+// before the first middleware with the name. The new [RoundTripper] has the
+// same middlewares when none has the name.
+func (t *RoundTripper) InsertBefore(name string, mws ...MiddlewareFunc) *RoundTripper {
+	edited, ns := insertNamed(t.middlewares, t.named, name, 0, mws)
+	return newRoundTripper(t.base, edited, ns)
+}
+
+// InsertAfter creates a new [RoundTripper] with the middlewares inserted after
+// the first middleware with the name. The new [RoundTripper] has the same
+// middlewares when none has the name.
+func (t *RoundTripper) InsertAfter(name string, mws ...MiddlewareFunc) *RoundTripper {
+	edited, ns := insertNamed(t.middlewares, t.named, name, 1, mws)
+	return newRoundTripper(t.base, edited, ns)
+}
@@ -0,0 +1,284 @@
+package httpc_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kraciasty/httpc"
+)
+
+func ExampleClient_Describe() {
+	tr := httpc.NewRoundTripper(http.DefaultTransport, httpc.Named("secure", httpc.Secure()))
+	c := httpc.NewClient(&http.Client{Transport: tr},
+		httpc.Named("retry", httpc.Retry(httpc.RetryPolicy{})),
+		httpc.Named("timeout", httpc.Timeout(time.Second)),
+	)
+
+	fmt.Println(c.Describe())
+	// Output:
+	// client: retry, timeout
+	// transport: secure
+	// base: *http.Transport
+}
+
+func TestNamed(t *testing.T) {
+	var calls []string
+	record := func(name string) httpc.MiddlewareFunc {
+		return func(next httpc.DoerFunc) httpc.DoerFunc {
+			return func(r *http.Request) (*http.Response, error) {
+				calls = append(calls, name)
+				return next(r)
+			}
+		}
+	}
+
+	c := httpc.NewClient(stubDoer,
+		httpc.Named("first", record("first")),
+		httpc.Named("placeholder", nil),
+		record("second"),
+	)
+	if err := doGet(t, c, t.Context(), "http://localhost"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if want := []string{"first", "second"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("Expected calls %v but got %v", want, calls)
+	}
+}
+
+func TestClient_Middlewares(t *testing.T) {
+	c := httpc.NewClient(stubDoer,
+		httpc.Named("secure", httpc.Secure()),
+		nil,
+		httpc.StripSlashes(true),
+		httpc.Named("placeholder", nil),
+	)
+
+	want := []httpc.MiddlewareInfo{
+		{Name: "secure", Func: "github.com/kraciasty/httpc.Secure"},
+		{Func: "github.com/kraciasty/httpc.StripSlashes"},
+		{Name: "placeholder"},
+	}
+	if got := c.Middlewares(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected middlewares %v but got %v", want, got)
+	}
+
+	sub := c.With(httpc.Named("ua", httpc.UserAgent("test")))
+	if got := sub.Middlewares(); len(got) != 4 || got[3].Name != "ua" {
+		t.Errorf("Expected the sub-client to add the named middleware but got %v", got)
+	}
+}
+
+func TestRoundTripper_Middlewares(t *testing.T) {
+	rl := httpc.NewRateLimiter(1, 1, httpc.HostKey)
+	tr := httpc.NewRoundTripper(http.DefaultTransport, httpc.Named("ratelimit", rl.Middleware))
+
+	want := []httpc.MiddlewareInfo{{Name: "ratelimit", Func: "github.com/kraciasty/httpc.(*RateLimiter).Middleware"}}
+	if got := tr.Middlewares(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected middlewares %v but got %v", want, got)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	tests := []struct {
+		name     string
+		describe func() httpc.ChainDescription
+		wantText string
+		wantJSON string
+	}{
+		{
+			name: "client",
+			describe: func() httpc.ChainDescription {
+				return httpc.NewClient(stubDoer, httpc.Named("secure", httpc.Secure())).Describe()
+			},
+			wantText: "client: secure\nbase: httpc.DoerFunc",
+			wantJSON: `{"layers":[{"kind":"client","middlewares":[{"name":"secure","func":"github.com/kraciasty/httpc.Secure"}]}],"base":"httpc.DoerFunc"}`,
+		},
+		{
+			name: "nested",
+			describe: func() httpc.ChainDescription {
+				tr := httpc.NewRoundTripper(httpc.NewRoundTripper(http.DefaultTransport), httpc.StripSlashes(true))
+				inner := httpc.NewClient(&http.Client{Transport: tr})
+				return httpc.NewClient(inner, httpc.Named("secure", httpc.Secure())).Describe()
+			},
+			wantText: "client: secure\nclient: \ntransport: github.com/kraciasty/httpc.StripSlashes\ntransport: \nbase: *http.Transport",
+			wantJSON: `{"layers":[` +
+				`{"kind":"client","middlewares":[{"name":"secure","func":"github.com/kraciasty/httpc.Secure"}]},` +
+				`{"kind":"client","middlewares":[]},` +
+				`{"kind":"transport","middlewares":[{"func":"github.com/kraciasty/httpc.StripSlashes"}]},` +
+				`{"kind":"transport","middlewares":[]}` +
+				`],"base":"*http.Transport"}`,
+		},
+		{
+			name: "default transport",
+			describe: func() httpc.ChainDescription {
+				return httpc.NewClient(http.DefaultClient).Describe()
+			},
+			wantText: "client: \nbase: *http.Transport",
+			wantJSON: `{"layers":[{"kind":"client","middlewares":[]}],"base":"*http.Transport"}`,
+		},
+		{
+			name: "round tripper",
+			describe: func() httpc.ChainDescription {
+				return httpc.NewRoundTripper(stubDoer, httpc.Named("secure", httpc.Secure())).Describe()
+			},
+			wantText: "transport: secure\nbase: httpc.DoerFunc",
+			wantJSON: `{"layers":[{"kind":"transport","middlewares":[{"name":"secure","func":"github.com/kraciasty/httpc.Secure"}]}],"base":"httpc.DoerFunc"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := tt.describe()
+			if got := d.String(); got != tt.wantText {
+				t.Errorf("Expected text %q but got %q", tt.wantText, got)
+			}
+
+			b, err := json.Marshal(d)
+			if err != nil {
+				t.Fatalf("Cannot marshal description: %v", err)
+			}
+			if got := string(b); got != tt.wantJSON {
+				t.Errorf("Expected JSON %s but got %s", tt.wantJSON, got)
+			}
+		})
+	}
+}
+
+func TestClient_edit(t *testing.T) {
+	var calls []string
+	record := func(name string) httpc.MiddlewareFunc {
+		return httpc.Named(name, func(next httpc.DoerFunc) httpc.DoerFunc {
+			return func(r *http.Request) (*http.Response, error) {
+				calls = append(calls, name)
+				return next(r)
+			}
+		})
+	}
+
+	base := httpc.NewClient(stubDoer, record("a"), record("b"), record("c"))
+
+	tests := []struct {
+		name string
+		edit func(c *httpc.Client) *httpc.Client
+		want []string
+	}{
+		{
+			name: "without",
+			edit: func(c *httpc.Client) *httpc.Client { return c.Without("b") },
+			want: []string{"a", "c"},
+		},
+		{
+			name: "without missing",
+			edit: func(c *httpc.Client) *httpc.Client { return c.Without("x") },
+			want: []string{"a", "b", "c"},
+		},
+		{
+			name: "replace",
+			edit: func(c *httpc.Client) *httpc.Client {
+				return c.Replace("b", func(next httpc.DoerFunc) httpc.DoerFunc {
+					return func(r *http.Request) (*http.Response, error) {
+						calls = append(calls, "b2")
+						return next(r)
+					}
+				})
+			},
+			want: []string{"a", "b2", "c"},
+		},
+		{
+			name: "replace keeps name",
+			edit: func(c *httpc.Client) *httpc.Client { return c.Replace("b", nil).InsertAfter("b", record("d")) },
+			want: []string{"a", "d", "c"},
+		},
+		{
+			name: "insert before",
+			edit: func(c *httpc.Client) *httpc.Client { return c.InsertBefore("a", record("x"), record("y")) },
+			want: []string{"x", "y", "a", "b", "c"},
+		},
+		{
+			name: "insert after",
+			edit: func(c *httpc.Client) *httpc.Client { return c.InsertAfter("c", record("x")) },
+			want: []string{"a", "b", "c", "x"},
+		},
+		{
+			name: "insert missing",
+			edit: func(c *httpc.Client) *httpc.Client { return c.InsertAfter("x", record("y")) },
+			want: []string{"a", "b", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = nil
+			if err := doGet(t, tt.edit(base), t.Context(), "http://localhost"); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(calls, tt.want) {
+				t.Errorf("Expected calls %v but got %v", tt.want, calls)
+			}
+
+			calls = nil
+			if err := doGet(t, base, t.Context(), "http://localhost"); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if want := []string{"a", "b", "c"}; !reflect.DeepEqual(calls, want) {
+				t.Errorf("Expected the base client to be unchanged but got calls %v", calls)
+			}
+		})
+	}
+}
+
+func TestRoundTripper_edit(t *testing.T) {
+	tr := httpc.NewRoundTripper(http.DefaultTransport,
+		httpc.Named("secure", httpc.Secure()),
+		httpc.Named("timeout", httpc.Timeout(time.Second)),
+	)
+
+	edited := tr.
+		Without("secure").
+		Replace("timeout", httpc.Timeout(time.Minute)).
+		InsertBefore("timeout", httpc.Named("ua", httpc.UserAgent("test"))).
+		InsertAfter("timeout", httpc.Named("accept", httpc.Accept("text/plain")))
+
+	var names []string
+	for _, mw := range edited.Middlewares() {
+		names = append(names, mw.Name)
+	}
+	if want := []string{"ua", "timeout", "accept"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Expected middlewares %v but got %v", want, names)
+	}
+	if got := len(tr.Middlewares()); got != 2 {
+		t.Errorf("Expected the base round tripper to be unchanged but got %d middlewares", got)
+	}
+}
+
+func TestNamed_introspection(t *testing.T) {
+	// Plain middlewares are only built with the next doer of the chain.
+	var builds int
+	plain := func(next httpc.DoerFunc) httpc.DoerFunc {
+		builds++
+		if next == nil {
+			t.Errorf("Unexpected build with a nil next doer")
+		}
+		return next
+	}
+
+	c := httpc.NewClient(stubDoer, httpc.Named("a", httpc.Secure()), plain)
+	tr := httpc.NewRoundTripper(http.DefaultTransport, plain, httpc.Named("b", httpc.Secure()))
+
+	c.Middlewares()
+	c.Describe()
+	c.Without("a").Replace("x", nil).InsertBefore("a", plain).InsertAfter("x", plain).With(plain)
+	tr.Middlewares()
+	tr.Describe()
+	tr.Without("b").Replace("x", nil).InsertBefore("b", plain).InsertAfter("x", plain).With(plain)
+
+	// The 6 clients and 6 round trippers build the plain middleware once, and
+	// twice after With.
+	if want := 14; builds != want {
+		t.Errorf("Expected %d builds but got %d", want, builds)
+	}
+}
@@ -0,0 +1,362 @@
+package httpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen indicates that the request was rejected by an open circuit.
+//
+// Use the [CircuitOpenError] type to retrieve the circuit key.
+var ErrCircuitOpen = errors.New("circuit open")
+
+// CircuitOpenError is returned by the [CircuitBreaker] middleware when
+// a request is rejected without being sent.
+type CircuitOpenError struct {
+	Key   string       // The key of the rejecting circuit.
+	State CircuitState // The state of the circuit at rejection.
+}
+
+// Error returns a string representation of the error with the circuit key.
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrCircuitOpen, e.Key)
+}
+
+// Is compares the [ErrCircuitOpen] with the target error.
+func (e *CircuitOpenError) Is(target error) bool {
+	return errors.Is(target, ErrCircuitOpen)
+}
+
+// CircuitState is the state of a circuit in the [CircuitBreaker].
+type CircuitState int
+
+const (
+	// CircuitClosed lets all requests through while tracking failures.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects all requests until the cool-down passes.
+	CircuitOpen
+	// CircuitHalfOpen lets a limited number of probe requests through.
+	CircuitHalfOpen
+)
+
+// String returns the name of the state.
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("CircuitState(%d)", int(s))
+	}
+}
+
+// CircuitBreakerOptions configures the [CircuitBreaker] middleware.
+//
+// The zero value is usable, see the field defaults.
+type CircuitBreakerOptions struct {
+	// Key returns the circuit key for the request.
+	// Defaults to [HostKey].
+	Key func(*http.Request) string
+
+	// Window is the duration of the rolling window in which the failures
+	// are counted. Defaults to 10 seconds.
+	Window time.Duration
+
+	// MinRequests is the minimum number of requests in the window before
+	// the circuit can open. Defaults to 10.
+	MinRequests int
+
+	// FailureRatio is the ratio of failed requests in the window that opens
+	// the circuit. Defaults to 0.5.
+	FailureRatio float64
+
+	// CoolDown is the time an open circuit waits before letting probe
+	// requests through. Defaults to 30 seconds.
+	CoolDown time.Duration
+
+	// HalfOpenRequests is the number of probe requests that must succeed to
+	// close a half-open circuit. It also limits the concurrent probes.
+	// Defaults to 1.
+	HalfOpenRequests int
+
+	// IsFailure reports whether the request outcome counts as a failure.
+	// Defaults to transport errors and 5xx status codes. Requests canceled
+	// with their context are not counted.
+	IsFailure func(*http.Response, error) bool
+
+	// OnStateChange is called after a circuit changes its state.
+	OnStateChange func(key string, from, to CircuitState)
+}
+
+func (o CircuitBreakerOptions) withDefaults() CircuitBreakerOptions {
+	if o.Key == nil {
+		o.Key = HostKey
+	}
+	if o.Window <= 0 {
+		o.Window = 10 * time.Second
+	}
+	if o.MinRequests <= 0 {
+		o.MinRequests = 10
+	}
+	if o.FailureRatio <= 0 {
+		o.FailureRatio = 0.5
+	}
+	if o.CoolDown <= 0 {
+		o.CoolDown = 30 * time.Second
+	}
+	if o.HalfOpenRequests <= 0 {
+		o.HalfOpenRequests = 1
+	}
+	if o.IsFailure == nil {
+		o.IsFailure = isFailure
+	}
+	return o
+}
+
+func isFailure(resp *http.Response, err error) bool {
+	return err != nil || resp.StatusCode >= http.StatusInternalServerError
+}
+
+// CircuitBreaker is a middleware that stops sending requests to failing
+// upstreams, tracked separately for each circuit key.
+//
+// A closed circuit counts the failures in a rolling window and opens when the
+// failure ratio is reached. An open circuit rejects requests with
+// a [CircuitOpenError] until the cool-down passes, then becomes half-open and
+// lets a limited number of probe requests through. Successful probes close the
+// circuit, while a failed probe opens it again. Requests canceled with their
+// context are neither successes nor failures.
+//
+// The circuits are shared by all chains built with the returned middleware.
+func CircuitBreaker(opts CircuitBreakerOptions) MiddlewareFunc {
+	opts = opts.withDefaults()
+	var (
+		mu       sync.Mutex
+		circuits = make(map[string]*circuit)
+	)
+
+	get := func(key string) *circuit {
+		mu.Lock()
+		defer mu.Unlock()
+
+		c, ok := circuits[key]
+		if !ok {
+			c = newCircuit(key, &opts)
+			circuits[key] = c
+		}
+		return c
+	}
+
+	return func(next DoerFunc) DoerFunc {
+		return func(r *http.Request) (*http.Response, error) {
+			c := get(opts.Key(r))
+			gen, err := c.allow(time.Now())
+			if err != nil {
+				return nil, err
+			}
+
+			// The outcome of a canceled or panicking request is unknown,
+			// so only its probe slot is released.
+			completed := false
+			defer func() {
+				if !completed {
+					c.release(gen)
+				}
+			}()
+
+			resp, err := next(r)
+			if errors.Is(err, context.Canceled) {
+				return resp, err
+			}
+
+			completed = true
+			c.done(gen, opts.IsFailure(resp, err), time.Now())
+			return resp, err
+		}
+	}
+}
+
+// circuit tracks the state of a single circuit key.
+type circuit struct {
+	key  string
+	opts *CircuitBreakerOptions
+
+	mu        sync.Mutex
+	state     CircuitState
+	gen       uint64 // incremented on state change to ignore stale outcomes
+	openedAt  time.Time
+	probes    int // half-open probes in flight
+	successes int // successful half-open probes
+	window    rollingWindow
+}
+
+func newCircuit(key string, opts *CircuitBreakerOptions) *circuit {
+	return &circuit{
+		key:    key,
+		opts:   opts,
+		window: newRollingWindow(opts.Window, 10),
+	}
+}
+
+// allow reports whether a request may proceed and returns the generation
+// the outcome should be reported for.
+func (c *circuit) allow(now time.Time) (uint64, error) {
+	c.mu.Lock()
+	var from CircuitState
+	changed := false
+	if c.state == CircuitOpen && now.Sub(c.openedAt) >= c.opts.CoolDown {
+		from, changed = c.setState(CircuitHalfOpen, now), true
+	}
+
+	var err error
+	switch c.state {
+	case CircuitOpen:
+		err = &CircuitOpenError{Key: c.key, State: c.state}
+	case CircuitHalfOpen:
+		if c.probes >= c.opts.HalfOpenRequests {
+			err = &CircuitOpenError{Key: c.key, State: c.state}
+			break
+		}
+		c.probes++
+	}
+	gen := c.gen
+	c.mu.Unlock()
+
+	if changed {
+		c.notify(from, CircuitHalfOpen)
+	}
+
+	return gen, err
+}
+
+// done records the outcome of a request allowed in the given generation.
+func (c *circuit) done(gen uint64, failed bool, now time.Time) {
+	c.mu.Lock()
+	if gen != c.gen {
+		c.mu.Unlock()
+		return
+	}
+
+	from, to := c.state, c.state
+	switch c.state {
+	case CircuitClosed:
+		c.window.record(now, failed)
+		total, failures := c.window.counts(now)
+		if total >= c.opts.MinRequests &&
+			float64(failures)/float64(total) >= c.opts.FailureRatio {
+			to = CircuitOpen
+		}
+	case CircuitHalfOpen:
+		c.probes--
+		if failed {
+			to = CircuitOpen
+			break
+		}
+
+		c.successes++
+		if c.successes >= c.opts.HalfOpenRequests {
+			to = CircuitClosed
+		}
+	}
+
+	if to != from {
+		c.setState(to, now)
+	}
+	c.mu.Unlock()
+
+	if to != from {
+		c.notify(from, to)
+	}
+}
+
+// release frees the probe slot of a request allowed in the given generation
+// without recording its outcome.
+func (c *circuit) release(gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen == c.gen && c.state == CircuitHalfOpen {
+		c.probes--
+	}
+}
+
+// setState transitions the circuit and returns the previous state.
+// It must be called with the lock held.
+func (c *circuit) setState(to CircuitState, now time.Time) CircuitState {
+	from := c.state
+	c.state = to
+	c.gen++
+	c.probes = 0
+	c.successes = 0
+	switch to {
+	case CircuitOpen:
+		c.openedAt = now
+	case CircuitClosed:
+		c.window.reset()
+	}
+	return from
+}
+
+func (c *circuit) notify(from, to CircuitState) {
+	if c.opts.OnStateChange != nil {
+		c.opts.OnStateChange(c.key, from, to)
+	}
+}
+
+// rollingWindow counts outcomes in a fixed number of time buckets.
+type rollingWindow struct {
+	size    time.Duration // duration of a single bucket
+	buckets []windowBucket
+}
+
+type windowBucket struct {
+	epoch    int64
+	total    int
+	failures int
+}
+
+func newRollingWindow(d time.Duration, n int) rollingWindow {
+	return rollingWindow{
+		size:    max(d/time.Duration(n), 1),
+		buckets: make([]windowBucket, n),
+	}
+}
+
+func (w *rollingWindow) epoch(now time.Time) int64 {
+	return now.UnixNano() / int64(w.size)
+}
+
+func (w *rollingWindow) record(now time.Time, failed bool) {
+	epoch := w.epoch(now)
+	b := &w.buckets[epoch%int64(len(w.buckets))]
+	if b.epoch != epoch {
+		*b = windowBucket{epoch: epoch}
+	}
+
+	b.total++
+	if failed {
+		b.failures++
+	}
+}
+
+func (w *rollingWindow) counts(now time.Time) (total, failures int) {
+	oldest := w.epoch(now) - int64(len(w.buckets))
+	for _, b := range w.buckets {
+		if b.epoch > oldest {
+			total += b.total
+			failures += b.failures
+		}
+	}
+	return total, failures
+}
+
+func (w *rollingWindow) reset() {
+	clear(w.buckets)
+}
@@ -0,0 +1,250 @@
+package httpc_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/kraciasty/httpc"
+)
+
+func ExampleCircuitBreaker() {
+	c := httpc.NewClient(
+		httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+			return nil, errors.New("upstream down")
+		}),
+		httpc.CircuitBreaker(httpc.CircuitBreakerOptions{
+			MinRequests: 2,
+			OnStateChange: func(key string, from, to httpc.CircuitState) {
+				fmt.Printf("%s: %s -> %s\n", key, from, to)
+			},
+		}),
+	)
+
+	for range 3 {
+		r, _ := http.NewRequest(http.MethodGet, "http://stuff.local", http.NoBody)
+		_, err := c.Do(r)
+		fmt.Println(err)
+	}
+	// Output:
+	// upstream down
+	// stuff.local: closed -> open
+	// upstream down
+	// circuit open: stuff.local
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	synctest.Run(func() {
+		var (
+			failing     = true
+			calls       int
+			transitions []string
+		)
+		doer := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+			calls++
+			if failing {
+				return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: http.NoBody}, nil
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		})
+		c := httpc.NewClient(doer, httpc.CircuitBreaker(httpc.CircuitBreakerOptions{
+			MinRequests:      4,
+			FailureRatio:     0.5,
+			CoolDown:         time.Minute,
+			HalfOpenRequests: 2,
+			OnStateChange: func(key string, from, to httpc.CircuitState) {
+				transitions = append(transitions, key+": "+from.String()+" -> "+to.String())
+			},
+		}))
+
+		do := func(host string) error {
+			t.Helper()
+			r, err := http.NewRequest(http.MethodGet, "http://"+host, http.NoBody)
+			if err != nil {
+				t.Fatalf("Cannot create request: %v", err)
+			}
+
+			resp, err := c.Do(r)
+			if err == nil {
+				resp.Body.Close()
+			}
+			return err
+		}
+
+		for range 4 {
+			if err := do("a.local"); err != nil {
+				t.Fatalf("Expected closed circuit to pass requests: %v", err)
+			}
+		}
+
+		err := do("a.local")
+		if !errors.Is(err, httpc.ErrCircuitOpen) {
+			t.Fatalf("Expected httpc.ErrCircuitOpen but got: %v", err)
+		}
+
+		var openErr *httpc.CircuitOpenError
+		if !errors.As(err, &openErr) {
+			t.Fatalf("Expected error to be of type *httpc.CircuitOpenError")
+		}
+		if openErr.Key != "a.local" || openErr.State != httpc.CircuitOpen {
+			t.Errorf("Unexpected error details: %+v", openErr)
+		}
+
+		if err := do("b.local"); err != nil {
+			t.Errorf("Expected other hosts to be unaffected: %v", err)
+		}
+
+		if calls != 5 {
+			t.Errorf("Expected 5 calls to reach the doer but got %d", calls)
+		}
+
+		// A failed probe opens the circuit again.
+		time.Sleep(time.Minute)
+		if err := do("a.local"); err != nil {
+			t.Fatalf("Expected probe to pass: %v", err)
+		}
+		if err := do("a.local"); !errors.Is(err, httpc.ErrCircuitOpen) {
+			t.Fatalf("Expected reopened circuit but got: %v", err)
+		}
+
+		// Successful probes close the circuit.
+		time.Sleep(time.Minute)
+		failing = false
+		for range 3 {
+			if err := do("a.local"); err != nil {
+				t.Fatalf("Expected requests to pass: %v", err)
+			}
+		}
+
+		want := []string{
+			"a.local: closed -> open",
+			"a.local: open -> half-open",
+			"a.local: half-open -> open",
+			"a.local: open -> half-open",
+			"a.local: half-open -> closed",
+		}
+		if !slices.Equal(transitions, want) {
+			t.Errorf("Expected transitions %q but got %q", want, transitions)
+		}
+	})
+}
+
+func TestCircuitBreaker_unknownOutcome(t *testing.T) {
+	synctest.Run(func() {
+		var transitions []string
+		doer := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+			if err := r.Context().Err(); err != nil {
+				return nil, err
+			}
+			if r.Header.Get("X-Panic") != "" {
+				panic("boom")
+			}
+			return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: http.NoBody}, nil
+		})
+		c := httpc.NewClient(doer, httpc.Recover(), httpc.CircuitBreaker(httpc.CircuitBreakerOptions{
+			MinRequests: 1,
+			CoolDown:    time.Minute,
+			OnStateChange: func(key string, from, to httpc.CircuitState) {
+				transitions = append(transitions, from.String()+" -> "+to.String())
+			},
+		}))
+
+		do := func(ctx context.Context, header http.Header) error {
+			t.Helper()
+			r, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://a.local", http.NoBody)
+			if err != nil {
+				t.Fatalf("Cannot create request: %v", err)
+			}
+			r.Header = header
+
+			resp, err := c.Do(r)
+			if err == nil {
+				resp.Body.Close()
+			}
+			return err
+		}
+
+		if err := do(context.Background(), http.Header{}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		time.Sleep(time.Minute)
+
+		// The canceled and panicking probes release their slots.
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := do(ctx, http.Header{}); !errors.Is(err, context.Canceled) {
+			t.Fatalf("Expected context.Canceled but got: %v", err)
+		}
+		if err := do(context.Background(), http.Header{"X-Panic": {"1"}}); !errors.Is(err, httpc.ErrPanicRecovered) {
+			t.Fatalf("Expected a recovered panic but got: %v", err)
+		}
+		if err := do(context.Background(), http.Header{}); err != nil {
+			t.Fatalf("Expected probe to pass: %v", err)
+		}
+
+		want := []string{"closed -> open", "open -> half-open", "half-open -> open"}
+		if !slices.Equal(transitions, want) {
+			t.Errorf("Expected transitions %q but got %q", want, transitions)
+		}
+	})
+}
+
+func TestCircuitBreaker_rollingWindow(t *testing.T) {
+	synctest.Run(func() {
+		doer := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+			return nil, errors.New("failure")
+		})
+		c := httpc.NewClient(doer, httpc.CircuitBreaker(httpc.CircuitBreakerOptions{
+			Window:      10 * time.Second,
+			MinRequests: 3,
+		}))
+
+		do := func() error {
+			r, err := http.NewRequest(http.MethodGet, "http://localhost", http.NoBody)
+			if err != nil {
+				t.Fatalf("Cannot create request: %v", err)
+			}
+			_, err = c.Do(r)
+			return err
+		}
+
+		for range 2 {
+			_ = do()
+			time.Sleep(10 * time.Second)
+		}
+
+		if err := do(); errors.Is(err, httpc.ErrCircuitOpen) {
+			t.Fatalf("Expected expired failures to be ignored")
+		}
+		if err := do(); errors.Is(err, httpc.ErrCircuitOpen) {
+			t.Fatalf("Expected circuit to stay closed below min requests")
+		}
+		_ = do()
+		if err := do(); !errors.Is(err, httpc.ErrCircuitOpen) {
+			t.Fatalf("Expected circuit to open but got: %v", err)
+		}
+	})
+}
+
+func TestCircuitState_String(t *testing.T) {
+	tests := []struct {
+		state httpc.CircuitState
+		want  string
+	}{
+		{state: httpc.CircuitClosed, want: "closed"},
+		{state: httpc.CircuitOpen, want: "open"},
+		{state: httpc.CircuitHalfOpen, want: "half-open"},
+		{state: httpc.CircuitState(42), want: "CircuitState(42)"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.state.String(); got != tt.want {
+			t.Errorf("Expected %q but got %q", tt.want, got)
+		}
+	}
+}
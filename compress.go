@@ -0,0 +1,320 @@
+package httpc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// ErrDecompressionBomb indicates that a decoded response body exceeded the
+// configured limits.
+//
+// Use the [DecompressionBombError] type to retrieve the details.
+var ErrDecompressionBomb = errors.New("decompression limit exceeded")
+
+// DecompressionBombError is returned when reading a response body decoded by
+// the [Decompress] middleware exceeds the configured limits.
+type DecompressionBombError struct {
+	Encoding string // The content encoding of the response.
+	Encoded  int64  // The number of encoded bytes read.
+	Decoded  int64  // The number of decoded bytes read.
+}
+
+// Error returns a string representation of the error with the sizes.
+func (e *DecompressionBombError) Error() string {
+	return fmt.Sprintf("%v: %s decoded %d bytes from %d bytes",
+		ErrDecompressionBomb, e.Encoding, e.Decoded, e.Encoded)
+}
+
+// Is compares the [ErrDecompressionBomb] with the target error.
+func (e *DecompressionBombError) Is(target error) bool {
+	return errors.Is(target, ErrDecompressionBomb)
+}
+
+// ErrUnsupportedEncoding indicates that the content encoding is not supported
+// by [CompressRequest].
+var ErrUnsupportedEncoding = errors.New("unsupported encoding")
+
+// Decoder returns a reader decoding the content encoded data from r.
+type Decoder func(r io.Reader) (io.ReadCloser, error)
+
+// DecompressOptions configures the [Decompress] middleware.
+type DecompressOptions struct {
+	// Decoders registers additional content encodings, such as "br" or
+	// "zstd", or replaces the built-in gzip and deflate decoders.
+	Decoders map[string]Decoder
+
+	// MaxSize is the maximum number of decoded bytes of a response body.
+	// Defaults to 1 GiB when zero, there is no limit when below zero.
+	MaxSize int64
+
+	// MaxRatio is the maximum ratio of decoded to encoded bytes of a response
+	// body. It is checked once the decoded body exceeds 1 MiB, so that small
+	// and highly compressible responses are not rejected. Defaults to 100 when
+	// zero, there is no limit when below zero.
+	MaxRatio float64
+}
+
+func (o DecompressOptions) withDefaults() DecompressOptions {
+	if o.MaxSize == 0 {
+		o.MaxSize = 1 << 30
+	}
+	if o.MaxRatio == 0 {
+		o.MaxRatio = 100
+	}
+	return o
+}
+
+// minRatioCheck is the decoded size from which the MaxRatio is checked.
+const minRatioCheck = 1 << 20
+
+var defaultDecoders = map[string]Decoder{
+	"gzip": func(r io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	},
+	"deflate": func(r io.Reader) (io.ReadCloser, error) {
+		// Some servers send a raw deflate stream instead of the zlib format.
+		br := bufio.NewReader(r)
+		if h, err := br.Peek(2); err == nil && isZlibHeader(h) {
+			return zlib.NewReader(br)
+		}
+		return flate.NewReader(br), nil
+	},
+}
+
+func isZlibHeader(h []byte) bool {
+	return h[0]&0x0f == 8 && (uint16(h[0])<<8|uint16(h[1]))%31 == 0
+}
+
+// Decompress is a middleware that advertises the supported content encodings
+// in the Accept-Encoding header and transparently decodes the response bodies.
+//
+// The gzip and deflate encodings are supported by default and more can be
+// registered with the [DecompressOptions]. Decoded responses have the
+// Content-Encoding and Content-Length headers removed and the
+// [http.Response] Uncompressed field set.
+//
+// Requests with the Accept-Encoding header already set are left untouched.
+// Reading a body beyond the configured limits fails with
+// a [DecompressionBombError].
+func Decompress(opts DecompressOptions) MiddlewareFunc {
+	opts = opts.withDefaults()
+	decoders := maps.Clone(defaultDecoders)
+	maps.Copy(decoders, opts.Decoders)
+
+	names := slices.Sorted(maps.Keys(decoders))
+	accept := strings.Join(names, ", ")
+
+	return func(next DoerFunc) DoerFunc {
+		return func(r *http.Request) (*http.Response, error) {
+			if r.Header.Get("Accept-Encoding") != "" {
+				return next(r)
+			}
+
+			r.Header.Set("Accept-Encoding", accept)
+			resp, err := next(r)
+			if err != nil || r.Method == http.MethodHead ||
+				resp.Body == nil || resp.Body == http.NoBody {
+				return resp, err
+			}
+
+			encoding := resp.Header.Get("Content-Encoding")
+			if encoding == "" || strings.EqualFold(encoding, "identity") {
+				return resp, nil
+			}
+
+			// Multiple encodings are listed in the order they were applied.
+			var chain []Decoder
+			for _, e := range slices.Backward(strings.Split(encoding, ",")) {
+				e = strings.ToLower(strings.TrimSpace(e))
+				if e == "identity" {
+					continue
+				}
+
+				dec, ok := decoders[e]
+				if !ok {
+					return resp, nil
+				}
+				chain = append(chain, dec)
+			}
+
+			resp.Body = &decodedBody{
+				raw:      resp.Body,
+				encoded:  &countingReader{r: resp.Body},
+				chain:    chain,
+				encoding: encoding,
+				opts:     opts,
+			}
+			resp.Header.Del("Content-Encoding")
+			resp.Header.Del("Content-Length")
+			resp.ContentLength = -1
+			resp.Uncompressed = true
+			return resp, nil
+		}
+	}
+}
+
+// decodedBody lazily decodes the raw body and enforces the size limits.
+type decodedBody struct {
+	raw      io.ReadCloser
+	encoded  *countingReader
+	chain    []Decoder
+	encoding string
+	opts     DecompressOptions
+
+	rd      io.Reader
+	closers []io.Closer
+	decoded int64
+	err     error
+}
+
+func (b *decodedBody) init() error {
+	var rd io.Reader = b.encoded
+	for _, dec := range b.chain {
+		rc, err := dec(rd)
+		if err != nil {
+			return fmt.Errorf("decode %s: %w", b.encoding, err)
+		}
+		b.closers = append(b.closers, rc)
+		rd = rc
+	}
+
+	b.rd = rd
+	return nil
+}
+
+func (b *decodedBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+
+	if b.rd == nil {
+		if err := b.init(); err != nil {
+			b.err = err
+			return 0, err
+		}
+	}
+
+	n, err := b.rd.Read(p)
+	b.decoded += int64(n)
+	if b.exceeded() {
+		b.err = &DecompressionBombError{
+			Encoding: b.encoding,
+			Encoded:  b.encoded.n,
+			Decoded:  b.decoded,
+		}
+		return n, b.err
+	}
+
+	return n, err
+}
+
+func (b *decodedBody) exceeded() bool {
+	if b.opts.MaxSize > 0 && b.decoded > b.opts.MaxSize {
+		return true
+	}
+
+	return b.opts.MaxRatio > 0 && b.decoded > minRatioCheck &&
+		float64(b.decoded) > b.opts.MaxRatio*float64(max(b.encoded.n, 1))
+}
+
+func (b *decodedBody) Close() error {
+	for _, c := range slices.Backward(b.closers) {
+		_ = c.Close()
+	}
+	return b.raw.Close()
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// CompressRequest is a middleware that compresses the request bodies of at
+// least minSize bytes with the gzip or deflate encoding.
+//
+// The body is buffered in memory, the Content-Encoding and Content-Length
+// headers are set and [http.Request.GetBody] returns the compressed body.
+// Requests that already have the Content-Encoding header are left untouched.
+// Requests fail with [ErrUnsupportedEncoding] when the encoding is not
+// supported.
+func CompressRequest(minSize int, encoding string) MiddlewareFunc {
+	if encoding != "gzip" && encoding != "deflate" {
+		err := fmt.Errorf("compress: %w %q", ErrUnsupportedEncoding, encoding)
+		return func(DoerFunc) DoerFunc {
+			return func(*http.Request) (*http.Response, error) {
+				return nil, err
+			}
+		}
+	}
+
+	return func(next DoerFunc) DoerFunc {
+		return func(r *http.Request) (*http.Response, error) {
+			if r.Body == nil || r.Body == http.NoBody ||
+				r.Header.Get("Content-Encoding") != "" ||
+				(r.ContentLength > 0 && r.ContentLength < int64(minSize)) {
+				return next(r)
+			}
+
+			data, err := io.ReadAll(r.Body)
+			_ = r.Body.Close()
+			if err != nil {
+				return nil, fmt.Errorf("read body: %w", err)
+			}
+
+			if len(data) >= minSize {
+				if data, err = compress(data, encoding); err != nil {
+					return nil, err
+				}
+				r.Header.Set("Content-Encoding", encoding)
+			}
+
+			r.ContentLength = int64(len(data))
+			r.Body = io.NopCloser(bytes.NewReader(data))
+			r.GetBody = func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(data)), nil
+			}
+
+			return next(r)
+		}
+	}
+}
+
+func compress(data []byte, encoding string) ([]byte, error) {
+	var (
+		buf bytes.Buffer
+		w   io.WriteCloser
+	)
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	default:
+		return nil, fmt.Errorf("compress: %w %q", ErrUnsupportedEncoding, encoding)
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return nil, fmt.Errorf("compress: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("compress: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,385 @@
+package httpc_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/kraciasty/httpc"
+)
+
+func encode(t *testing.T, encoding string, data []byte) []byte {
+	t.Helper()
+
+	var (
+		buf bytes.Buffer
+		w   io.WriteCloser
+		err error
+	)
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, err = flate.NewWriter(&buf, flate.BestCompression)
+	case "base64":
+		w = base64.NewEncoder(base64.StdEncoding, &buf)
+	}
+	if err != nil {
+		t.Fatalf("Cannot create writer: %v", err)
+	}
+
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("Cannot encode: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Cannot encode: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func encodedDoer(encoding string, body []byte) httpc.DoerFunc {
+	return func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			Header:        http.Header{"Content-Encoding": {encoding}, "Content-Length": {"1"}},
+			Body:          io.NopCloser(bytes.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       r,
+		}, nil
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	plain := []byte(strings.Repeat("hello world ", 100))
+	base64Decoder := func(r io.Reader) (io.ReadCloser, error) {
+		return io.NopCloser(base64.NewDecoder(base64.StdEncoding, r)), nil
+	}
+
+	tests := []struct {
+		name           string
+		opts           httpc.DecompressOptions
+		acceptEncoding string
+		encoding       string
+		body           []byte
+		wantAccept     string
+		wantBody       []byte
+		wantEncoding   string
+		wantErr        error
+	}{
+		{
+			name:       "gzip",
+			encoding:   "gzip",
+			body:       encode(t, "gzip", plain),
+			wantAccept: "deflate, gzip",
+			wantBody:   plain,
+		},
+		{
+			name:       "deflate",
+			encoding:   "deflate",
+			body:       encode(t, "deflate", plain),
+			wantAccept: "deflate, gzip",
+			wantBody:   plain,
+		},
+		{
+			name:       "raw deflate",
+			encoding:   "deflate",
+			body:       encode(t, "raw-deflate", plain),
+			wantAccept: "deflate, gzip",
+			wantBody:   plain,
+		},
+		{
+			name:         "identity",
+			encoding:     "identity",
+			body:         plain,
+			wantAccept:   "deflate, gzip",
+			wantBody:     plain,
+			wantEncoding: "identity",
+		},
+		{
+			name:       "custom decoder and multiple encodings",
+			opts:       httpc.DecompressOptions{Decoders: map[string]httpc.Decoder{"base64": base64Decoder}},
+			encoding:   "gzip, base64",
+			body:       encode(t, "base64", encode(t, "gzip", plain)),
+			wantAccept: "base64, deflate, gzip",
+			wantBody:   plain,
+		},
+		{
+			name:         "unknown encoding",
+			encoding:     "br",
+			body:         []byte("brotli"),
+			wantAccept:   "deflate, gzip",
+			wantBody:     []byte("brotli"),
+			wantEncoding: "br",
+		},
+		{
+			name:           "accept encoding set by the caller",
+			acceptEncoding: "gzip",
+			encoding:       "gzip",
+			body:           encode(t, "gzip", plain),
+			wantAccept:     "gzip",
+			wantBody:       encode(t, "gzip", plain),
+			wantEncoding:   "gzip",
+		},
+		{
+			name:       "max size",
+			opts:       httpc.DecompressOptions{MaxSize: 100},
+			encoding:   "gzip",
+			body:       encode(t, "gzip", plain),
+			wantAccept: "deflate, gzip",
+			wantErr:    httpc.ErrDecompressionBomb,
+		},
+		{
+			name:       "max ratio",
+			opts:       httpc.DecompressOptions{MaxRatio: 100},
+			encoding:   "gzip",
+			body:       encode(t, "gzip", make([]byte, 10<<20)),
+			wantAccept: "deflate, gzip",
+			wantErr:    httpc.ErrDecompressionBomb,
+		},
+		{
+			name:       "invalid data",
+			encoding:   "gzip",
+			body:       []byte("definitely not gzip data"),
+			wantAccept: "deflate, gzip",
+			wantErr:    gzip.ErrHeader,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotAccept string
+			doer := encodedDoer(tt.encoding, tt.body)
+			spy := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+				gotAccept = r.Header.Get("Accept-Encoding")
+				return doer(r)
+			})
+
+			c := httpc.NewClient(spy, httpc.Decompress(tt.opts))
+			req, err := http.NewRequest(http.MethodGet, "http://localhost", http.NoBody)
+			if err != nil {
+				t.Fatalf("Cannot create request: %v", err)
+			}
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+
+			resp, err := c.Do(req)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if gotAccept != tt.wantAccept {
+				t.Errorf("Expected Accept-Encoding %q but got %q", tt.wantAccept, gotAccept)
+			}
+
+			body, err := io.ReadAll(resp.Body)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Expected error %v but got: %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Cannot read body: %v", err)
+			}
+
+			if !bytes.Equal(body, tt.wantBody) {
+				t.Errorf("Unexpected body: %q", body)
+			}
+			if got := resp.Header.Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Errorf("Expected Content-Encoding %q but got %q", tt.wantEncoding, got)
+			}
+			if tt.wantEncoding == "" &&
+				(resp.ContentLength != -1 || !resp.Uncompressed || resp.Header.Get("Content-Length") != "") {
+				t.Errorf("Expected content length to be reset")
+			}
+		})
+	}
+}
+
+func TestDecompress_bombError(t *testing.T) {
+	doer := encodedDoer("gzip", encode(t, "gzip", make([]byte, 1000)))
+	c := httpc.NewClient(doer, httpc.Decompress(httpc.DecompressOptions{MaxSize: 10}))
+	req, err := http.NewRequest(http.MethodGet, "http://localhost", http.NoBody)
+	if err != nil {
+		t.Fatalf("Cannot create request: %v", err)
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	_, err = io.ReadAll(resp.Body)
+	var bombErr *httpc.DecompressionBombError
+	if !errors.As(err, &bombErr) {
+		t.Fatalf("Expected error to be of type *httpc.DecompressionBombError but got: %v", err)
+	}
+	if bombErr.Encoding != "gzip" || bombErr.Decoded <= 10 || bombErr.Encoded == 0 {
+		t.Errorf("Unexpected error details: %+v", bombErr)
+	}
+}
+
+func TestDecompress_defaultLimits(t *testing.T) {
+	bomb := encode(t, "gzip", make([]byte, 10<<20))
+
+	tests := []struct {
+		name    string
+		opts    httpc.DecompressOptions
+		wantErr error
+	}{
+		{name: "default", wantErr: httpc.ErrDecompressionBomb},
+		{name: "no limits", opts: httpc.DecompressOptions{MaxSize: -1, MaxRatio: -1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := httpc.NewClient(encodedDoer("gzip", bomb), httpc.Decompress(tt.opts))
+			req, err := http.NewRequest(http.MethodGet, "http://localhost", http.NoBody)
+			if err != nil {
+				t.Fatalf("Cannot create request: %v", err)
+			}
+
+			resp, err := c.Do(req)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			defer resp.Body.Close()
+
+			_, err = io.Copy(io.Discard, resp.Body)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected error %v but got: %v", tt.wantErr, err)
+			}
+			var bombErr *httpc.DecompressionBombError
+			if tt.wantErr != nil && !errors.As(err, &bombErr) {
+				t.Errorf("Expected error to be of type *httpc.DecompressionBombError but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestCompressRequest(t *testing.T) {
+	tests := []struct {
+		name         string
+		minSize      int
+		encoding     string
+		body         io.Reader
+		header       http.Header
+		wantEncoding string
+		wantErr      error
+	}{
+		{
+			name:         "gzip",
+			minSize:      10,
+			encoding:     "gzip",
+			body:         strings.NewReader(strings.Repeat("a", 100)),
+			wantEncoding: "gzip",
+		},
+		{
+			name:         "deflate with unknown length",
+			minSize:      10,
+			encoding:     "deflate",
+			body:         io.MultiReader(strings.NewReader(strings.Repeat("a", 100))),
+			wantEncoding: "deflate",
+		},
+		{
+			name:     "below min size",
+			minSize:  1000,
+			encoding: "gzip",
+			body:     strings.NewReader(strings.Repeat("a", 100)),
+		},
+		{
+			name:     "below min size with unknown length",
+			minSize:  1000,
+			encoding: "gzip",
+			body:     io.MultiReader(strings.NewReader(strings.Repeat("a", 100))),
+		},
+		{
+			name:         "already encoded",
+			encoding:     "gzip",
+			body:         strings.NewReader(strings.Repeat("a", 100)),
+			header:       http.Header{"Content-Encoding": {"br"}},
+			wantEncoding: "br",
+		},
+		{
+			name:     "unsupported encoding",
+			encoding: "br",
+			body:     strings.NewReader(strings.Repeat("a", 100)),
+			wantErr:  httpc.ErrUnsupportedEncoding,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *http.Request
+			spy := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+				got = r
+				return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+			})
+
+			c := httpc.NewClient(spy, httpc.CompressRequest(tt.minSize, tt.encoding))
+			req, err := http.NewRequest(http.MethodPost, "http://localhost", tt.body)
+			if err != nil {
+				t.Fatalf("Cannot create request: %v", err)
+			}
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+
+			_, err = c.Do(req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected error %v but got: %v", tt.wantErr, err)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			checkHeader(t, got, "Content-Encoding", tt.wantEncoding)
+			body, err := io.ReadAll(got.Body)
+			if err != nil {
+				t.Fatalf("Cannot read body: %v", err)
+			}
+
+			if tt.header == nil && int64(len(body)) != got.ContentLength {
+				t.Errorf("Expected content length %d but got %d", len(body), got.ContentLength)
+			}
+
+			if got.GetBody != nil {
+				rewound, _ := got.GetBody()
+				again, _ := io.ReadAll(rewound)
+				if !bytes.Equal(body, again) {
+					t.Errorf("Expected GetBody to return the sent body")
+				}
+			}
+
+			var rd io.Reader = bytes.NewReader(body)
+			switch tt.wantEncoding {
+			case "gzip":
+				rd, err = gzip.NewReader(rd)
+			case "deflate":
+				rd, err = zlib.NewReader(rd)
+			}
+			if err != nil {
+				t.Fatalf("Cannot decode body: %v", err)
+			}
+			decoded, err := io.ReadAll(rd)
+			if err != nil {
+				t.Fatalf("Cannot decode body: %v", err)
+			}
+			if string(decoded) != strings.Repeat("a", 100) {
+				t.Errorf("Unexpected decoded body: %q", decoded)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package httpc
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// RequestOption is a typed key of a per-request option stored in the request
+// context. It lets a single shared client be tuned for a call without
+// building a sub-client.
+//
+// The built-in middlewares read the options below and custom middlewares may
+// define their own with [NewRequestOption].
+type RequestOption[T any] struct {
+	name string
+}
+
+// NewRequestOption returns a new [RequestOption] with the given name.
+// The name is used for debugging only, each option is a distinct key.
+func NewRequestOption[T any](name string) *RequestOption[T] {
+	return &RequestOption[T]{name: name}
+}
+
+// With returns a copy of the context with the option set to v.
+func (o *RequestOption[T]) With(ctx context.Context, v T) context.Context {
+	return context.WithValue(ctx, o, v)
+}
+
+// Value returns the option value from the context and reports whether it was
+// set.
+func (o *RequestOption[T]) Value(ctx context.Context) (T, bool) {
+	v, ok := ctx.Value(o).(T)
+	return v, ok
+}
+
+// String returns the option name.
+func (o *RequestOption[T]) String() string {
+	return "httpc option " + o.name
+}
+
+var (
+	// RequestTimeout overrides the timeout of the [Timeout] middleware.
+	RequestTimeout = NewRequestOption[time.Duration]("timeout")
+
+	// SkipRetry disables the [Retry] middleware when set to true.
+	SkipRetry = NewRequestOption[bool]("skip retry")
+
+	// SkipCache makes the [Cache] middleware neither serve nor store the
+	// response when set to true.
+	SkipCache = NewRequestOption[bool]("skip cache")
+
+	// LogAttrs adds extra attributes to the record of the [Log] middleware.
+	LogAttrs = NewRequestOption[[]slog.Attr]("log attrs")
+)
+
+// requestMiddlewaresKey is the context key of the per-request middlewares.
+type requestMiddlewaresKey struct{}
+
+// WithRequestMiddleware returns a copy of the context with middlewares that
+// should be applied to a single request.
+//
+// The middlewares are applied by the first [Client] or [RoundTripper] that
+// handles the request, after its own middlewares and right before its base
+// doer, as if they were appended with With. Calling it again appends to the
+// previously added middlewares.
+func WithRequestMiddleware(ctx context.Context, mws ...MiddlewareFunc) context.Context {
+	prev, _ := ctx.Value(requestMiddlewaresKey{}).([]MiddlewareFunc)
+	return context.WithValue(ctx, requestMiddlewaresKey{}, slices.Concat(prev, mws))
+}
+
+// withRequestMiddlewares wraps the base doer with the per-request middlewares
+// found in the request context. The middlewares are removed from the context
+// so that they are not applied again by nested clients or round trippers.
+func withRequestMiddlewares(do DoerFunc) DoerFunc {
+	return func(r *http.Request) (*http.Response, error) {
+		mws, _ := r.Context().Value(requestMiddlewaresKey{}).([]MiddlewareFunc)
+		if len(mws) == 0 {
+			return do(r)
+		}
+
+		ctx := context.WithValue(r.Context(), requestMiddlewaresKey{}, []MiddlewareFunc(nil))
+		return applyMiddlewares(do, mws...)(r.WithContext(ctx))
+	}
+}
@@ -0,0 +1,217 @@
+package httpc_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kraciasty/httpc"
+)
+
+func ExampleWithRequestMiddleware() {
+	c := httpc.NewClient(stubDoer, httpc.UserAgent("shared"))
+
+	ctx := httpc.WithRequestMiddleware(context.Background(), httpc.SetHeader("X-Tenant", "acme"))
+	r, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://stuff.local", http.NoBody)
+	resp, _ := c.Do(r)
+	defer resp.Body.Close()
+
+	fmt.Println(resp.Request.Header.Get("X-Tenant"))
+	// Output: acme
+}
+
+func TestWithRequestMiddleware(t *testing.T) {
+	var calls []string
+	record := func(name string) httpc.MiddlewareFunc {
+		return func(next httpc.DoerFunc) httpc.DoerFunc {
+			return func(r *http.Request) (*http.Response, error) {
+				calls = append(calls, name)
+				return next(r)
+			}
+		}
+	}
+
+	tests := []struct {
+		name string
+		doer func() httpc.Doer
+		ctx  func() context.Context
+		want []string
+	}{
+		{
+			name: "none",
+			doer: func() httpc.Doer { return httpc.NewClient(stubDoer, record("client")) },
+			ctx:  context.Background,
+			want: []string{"client"},
+		},
+		{
+			name: "after client middlewares",
+			doer: func() httpc.Doer { return httpc.NewClient(stubDoer, record("client")) },
+			ctx: func() context.Context {
+				return httpc.WithRequestMiddleware(context.Background(), record("request"), nil)
+			},
+			want: []string{"client", "request"},
+		},
+		{
+			name: "appended",
+			doer: func() httpc.Doer { return httpc.NewClient(stubDoer) },
+			ctx: func() context.Context {
+				ctx := httpc.WithRequestMiddleware(context.Background(), record("first"))
+				return httpc.WithRequestMiddleware(ctx, record("second"))
+			},
+			want: []string{"first", "second"},
+		},
+		{
+			name: "applied once by nested clients",
+			doer: func() httpc.Doer {
+				rt := httpc.NewRoundTripper(stubDoer, record("transport"))
+				inner := httpc.NewClient(&http.Client{Transport: rt}, record("inner"))
+				return httpc.NewClient(inner, record("outer"))
+			},
+			ctx: func() context.Context {
+				return httpc.WithRequestMiddleware(context.Background(), record("request"))
+			},
+			want: []string{"outer", "request", "inner", "transport"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = nil
+			if err := doGet(t, tt.doer(), tt.ctx(), "http://localhost"); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !slices.Equal(calls, tt.want) {
+				t.Errorf("Expected calls %v but got %v", tt.want, calls)
+			}
+		})
+	}
+}
+
+func TestRequestOption(t *testing.T) {
+	opt := httpc.NewRequestOption[string]("tenant")
+
+	if _, ok := opt.Value(context.Background()); ok {
+		t.Errorf("Expected option to be unset")
+	}
+
+	ctx := opt.With(context.Background(), "acme")
+	if v, ok := opt.Value(ctx); !ok || v != "acme" {
+		t.Errorf("Expected option value %q but got %q", "acme", v)
+	}
+
+	other := httpc.NewRequestOption[string]("tenant")
+	if _, ok := other.Value(ctx); ok {
+		t.Errorf("Expected options with the same name to be distinct")
+	}
+
+	if got, want := opt.String(), "httpc option tenant"; got != want {
+		t.Errorf("Expected name %q but got %q", want, got)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	deadline := func(r *http.Request) (*http.Response, error) {
+		if _, ok := r.Context().Deadline(); !ok {
+			return nil, errors.New("no deadline")
+		}
+		return stubDoer(r)
+	}
+
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		ctx     context.Context
+		wantErr bool
+	}{
+		{
+			name:    "configured",
+			timeout: time.Minute,
+			ctx:     context.Background(),
+		},
+		{
+			name:    "disabled",
+			timeout: 0,
+			ctx:     context.Background(),
+			wantErr: true,
+		},
+		{
+			name:    "enabled for request",
+			timeout: 0,
+			ctx:     httpc.RequestTimeout.With(context.Background(), time.Minute),
+		},
+		{
+			name:    "disabled for request",
+			timeout: time.Minute,
+			ctx:     httpc.RequestTimeout.With(context.Background(), 0),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := httpc.NewClient(httpc.DoerFunc(deadline), httpc.Timeout(tt.timeout))
+			err := doGet(t, c, tt.ctx, "http://localhost")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v but got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestSkipRetry(t *testing.T) {
+	doer := &attemptsDoer{attempts: []stubAttempt{{status: http.StatusServiceUnavailable}}}
+	c := httpc.NewClient(doer, httpc.Retry(httpc.RetryPolicy{MaxAttempts: 3}))
+
+	ctx := httpc.SkipRetry.With(context.Background(), true)
+	if err := doGet(t, c, ctx, "http://localhost"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(doer.bodies) != 1 {
+		t.Errorf("Expected 1 attempt but got %d", len(doer.bodies))
+	}
+}
+
+func TestSkipCache(t *testing.T) {
+	o := &origin{handler: func(r *http.Request) (*http.Response, error) {
+		return originResponse(r, http.StatusOK, http.Header{"Cache-Control": {"max-age=60"}}, "body"), nil
+	}}
+	c := newCacheClient(o)
+
+	ctx := httpc.SkipCache.With(context.Background(), true)
+	for range 2 {
+		if err := doGet(t, c, ctx, "http://localhost/resource"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if status, _, _ := cachedGet(t, c, nil); status != "MISS" {
+		t.Errorf("Expected the skipped responses not to be stored but got status %q", status)
+	}
+	if o.calls != 3 {
+		t.Errorf("Expected 3 origin calls but got %d", o.calls)
+	}
+}
+
+func TestLogAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	c := httpc.NewClient(stubDoer, httpc.Log(logger, httpc.LogOptions{}))
+
+	ctx := httpc.LogAttrs.With(context.Background(), []slog.Attr{slog.String("operation", "list users")})
+	if err := doGet(t, c, ctx, "http://localhost"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	records := logRecords(t, &buf)
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 record but got %d", len(records))
+	}
+	if got := records[0]["operation"]; got != "list users" {
+		t.Errorf("Expected operation attribute %q but got %v", "list users", got)
+	}
+}
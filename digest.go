@@ -0,0 +1,282 @@
+package httpc
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/kraciasty/httpc/internal/challenge"
+	"github.com/kraciasty/httpc/internal/httpbody"
+)
+
+type digestAlgorithm struct {
+	name string
+	hash func() hash.Hash
+}
+
+// digestAlgorithms are the supported digest algorithms by preference.
+var digestAlgorithms = []digestAlgorithm{
+	{"SHA-512-256", sha512.New512_256},
+	{"SHA-256", sha256.New},
+	{"MD5", md5.New},
+}
+
+// AuthorizationDigest is a middleware that authenticates the requests with
+// HTTP Digest Access Authentication (RFC 7616).
+//
+// The request is sent without credentials first. When the server responds
+// with 401 Unauthorized and a Digest challenge, the request is retried with
+// the Authorization header computed for the challenge. The challenge is
+// cached per host, so the next requests are authorized upfront with an
+// incremented nonce count, and renewed when the server reports a stale nonce.
+//
+// The MD5, SHA-256 and SHA-512-256 algorithms and their session variants are
+// supported, preferring the strongest one offered. The auth quality of
+// protection is preferred over auth-int, which hashes the request body.
+// Requests with a body are retried only if the body can be rewound with
+// [http.Request.GetBody].
+func AuthorizationDigest(user, pass string) MiddlewareFunc {
+	d := &digestAuth{user: user, pass: pass, challenges: make(map[string]*digestChallenge)}
+
+	return func(next DoerFunc) DoerFunc {
+		return func(r *http.Request) (*http.Response, error) {
+			host := strings.ToLower(r.URL.Host)
+
+			sent := d.challenge(host)
+			if sent != nil {
+				if err := d.authorize(r, sent); err != nil {
+					return nil, err
+				}
+			}
+
+			resp, err := next(r)
+			if err != nil || resp.StatusCode != http.StatusUnauthorized {
+				return resp, err
+			}
+
+			c, ok := parseDigestChallenge(resp.Header)
+			if !ok || !httpbody.Rewindable(r) {
+				return resp, nil
+			}
+			if sent != nil && !c.stale && c.nonce == sent.nonce {
+				// The credentials were rejected.
+				return resp, nil
+			}
+			d.setChallenge(host, c)
+
+			req, err := httpbody.Rewind(r)
+			if err != nil {
+				return resp, nil
+			}
+			httpbody.Drain(resp)
+
+			if err := d.authorize(req, c); err != nil {
+				return nil, err
+			}
+			return next(req)
+		}
+	}
+}
+
+type digestAuth struct {
+	user, pass string
+
+	mu         sync.Mutex
+	challenges map[string]*digestChallenge // Keyed by the host.
+}
+
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	hash      func() hash.Hash
+	session   bool
+	qop       string
+	userhash  bool
+	stale     bool
+
+	nc uint32 // The nonce count, guarded by the digestAuth mutex.
+}
+
+func (d *digestAuth) challenge(host string) *digestChallenge {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.challenges[host]
+}
+
+func (d *digestAuth) setChallenge(host string, c *digestChallenge) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.challenges[host] = c
+}
+
+// authorize sets the Authorization header of the request for the challenge.
+func (d *digestAuth) authorize(r *http.Request, c *digestChallenge) error {
+	d.mu.Lock()
+	c.nc++
+	nc := fmt.Sprintf("%08x", c.nc)
+	d.mu.Unlock()
+
+	h := func(parts ...string) string {
+		sum := c.hash()
+		sum.Write([]byte(strings.Join(parts, ":")))
+		return hex.EncodeToString(sum.Sum(nil))
+	}
+
+	cnonce := newCnonce()
+	ha1 := h(d.user, c.realm, d.pass)
+	if c.session {
+		ha1 = h(ha1, c.nonce, cnonce)
+	}
+
+	uri := r.URL.RequestURI()
+	ha2 := h(r.Method, uri)
+	if c.qop == "auth-int" {
+		body, err := digestBody(r)
+		if err != nil {
+			return fmt.Errorf("digest auth: %w", err)
+		}
+		ha2 = h(r.Method, uri, h(string(body)))
+	}
+
+	var response string
+	if c.qop != "" {
+		response = h(ha1, c.nonce, nc, cnonce, c.qop, ha2)
+	} else {
+		response = h(ha1, c.nonce, ha2)
+	}
+
+	username := d.user
+	if c.userhash {
+		username = h(d.user, c.realm)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Digest username=%s, realm=%s, uri=%s, algorithm=%s, nonce=%s",
+		quote(username), quote(c.realm), quote(uri), c.algorithm, quote(c.nonce))
+	if c.qop != "" {
+		fmt.Fprintf(&b, ", nc=%s, cnonce=%s, qop=%s", nc, quote(cnonce), c.qop)
+	}
+	fmt.Fprintf(&b, ", response=%s", quote(response))
+	if c.opaque != "" {
+		fmt.Fprintf(&b, ", opaque=%s", quote(c.opaque))
+	}
+	if c.userhash {
+		b.WriteString(", userhash=true")
+	}
+
+	r.Header.Set("Authorization", b.String())
+	return nil
+}
+
+// parseDigestChallenge returns the Digest challenge of the response headers
+// with the strongest supported algorithm.
+func parseDigestChallenge(h http.Header) (*digestChallenge, bool) {
+	var best *digestChallenge
+	bestRank := len(digestAlgorithms)
+	for _, c := range challenge.Parse(h.Values("WWW-Authenticate")) {
+		if !strings.EqualFold(c.Scheme, "Digest") || c.Params["nonce"] == "" {
+			continue
+		}
+
+		algorithm := c.Params["algorithm"]
+		if algorithm == "" {
+			algorithm = "MD5"
+		}
+		name, session := strings.CutSuffix(strings.ToUpper(algorithm), "-SESS")
+		rank := slices.IndexFunc(digestAlgorithms, func(a digestAlgorithm) bool {
+			return a.name == name
+		})
+		if rank < 0 || rank >= bestRank {
+			continue
+		}
+
+		qop, ok := selectQop(c.Params["qop"])
+		if !ok {
+			continue
+		}
+
+		bestRank = rank
+		best = &digestChallenge{
+			realm:     c.Params["realm"],
+			nonce:     c.Params["nonce"],
+			opaque:    c.Params["opaque"],
+			algorithm: algorithm,
+			hash:      digestAlgorithms[rank].hash,
+			session:   session,
+			qop:       qop,
+			userhash:  strings.EqualFold(c.Params["userhash"], "true"),
+			stale:     strings.EqualFold(c.Params["stale"], "true"),
+		}
+	}
+	return best, best != nil
+}
+
+// selectQop returns the preferred quality of protection of the offered ones.
+// An empty offer selects the RFC 2069 compatible mode without qop.
+func selectQop(offered string) (string, bool) {
+	if offered == "" {
+		return "", true
+	}
+
+	var authInt bool
+	for _, qop := range strings.Split(offered, ",") {
+		switch strings.TrimSpace(qop) {
+		case "auth":
+			return "auth", true
+		case "auth-int":
+			authInt = true
+		}
+	}
+	return "auth-int", authInt
+}
+
+// digestBody returns the request body to compute a digest of, keeping the
+// request body intact. A body without [http.Request.GetBody] is buffered in
+// memory and made rewindable.
+func digestBody(r *http.Request) ([]byte, error) {
+	switch {
+	case r.Body == nil || r.Body == http.NoBody:
+		return nil, nil
+	case r.GetBody != nil:
+		body, err := r.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		return io.ReadAll(body)
+	}
+
+	b, err := io.ReadAll(r.Body)
+	_ = r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(b))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(b)), nil
+	}
+	return b, nil
+}
+
+func newCnonce() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// quote returns the quoted-string of the value.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,263 @@
+package httpc_test
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kraciasty/httpc"
+	"github.com/kraciasty/httpc/internal/challenge"
+)
+
+// A server requiring Digest authentication of the "Mufasa" user with the
+// "Circle of Life" password. It records the nonce counts and the bodies.
+type digestServer struct {
+	challenges []string
+	nonce      string
+
+	requests int
+	ncs      []string
+	bodies   []string
+}
+
+func (s *digestServer) Do(r *http.Request) (*http.Response, error) {
+	s.requests++
+	body, _ := io.ReadAll(r.Body)
+	s.bodies = append(s.bodies, string(body))
+
+	unauthorized := func(stale bool) (*http.Response, error) {
+		h := http.Header{}
+		for _, c := range s.challenges {
+			c = strings.ReplaceAll(c, "NONCE", s.nonce)
+			if stale {
+				c += ", stale=true"
+			}
+			h.Add("WWW-Authenticate", c)
+		}
+		return &http.Response{StatusCode: http.StatusUnauthorized, Header: h, Body: http.NoBody}, nil
+	}
+
+	auth := challenge.Parse([]string{r.Header.Get("Authorization")})
+	if len(auth) != 1 || auth[0].Scheme != "Digest" {
+		return unauthorized(false)
+	}
+
+	p := auth[0].Params
+	if p["nonce"] != s.nonce {
+		return unauthorized(true)
+	}
+	s.ncs = append(s.ncs, p["nc"])
+
+	algorithm, session := strings.CutSuffix(p["algorithm"], "-sess")
+	newHash := map[string]func() hash.Hash{
+		"MD5":         md5.New,
+		"SHA-256":     sha256.New,
+		"SHA-512-256": sha512.New512_256,
+	}[algorithm]
+	h := func(s string) string {
+		sum := newHash()
+		sum.Write([]byte(s))
+		return hex.EncodeToString(sum.Sum(nil))
+	}
+
+	ha1 := h("Mufasa:" + p["realm"] + ":Circle of Life")
+	if session {
+		ha1 = h(ha1 + ":" + p["nonce"] + ":" + p["cnonce"])
+	}
+	ha2 := h(r.Method + ":" + p["uri"])
+	if p["qop"] == "auth-int" {
+		ha2 = h(r.Method + ":" + p["uri"] + ":" + h(string(body)))
+	}
+	want := h(ha1 + ":" + p["nonce"] + ":" + ha2)
+	if p["qop"] != "" {
+		want = h(ha1 + ":" + p["nonce"] + ":" + p["nc"] + ":" + p["cnonce"] + ":" + p["qop"] + ":" + ha2)
+	}
+
+	username := "Mufasa"
+	if p["userhash"] == "true" {
+		username = h("Mufasa:" + p["realm"])
+	}
+	if p["response"] != want || p["username"] != username || p["uri"] != r.URL.RequestURI() {
+		return unauthorized(false)
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+}
+
+func ExampleAuthorizationDigest() {
+	srv := &digestServer{
+		challenges: []string{`Digest realm="http-auth@example.org", qop="auth", algorithm=SHA-256, nonce="NONCE"`},
+		nonce:      "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v",
+	}
+	c := httpc.NewClient(srv, httpc.AuthorizationDigest("Mufasa", "Circle of Life"))
+
+	r, _ := http.NewRequest(http.MethodGet, "http://stuff.local/dir/index.html", http.NoBody)
+	resp, _ := c.Do(r)
+	defer resp.Body.Close()
+	fmt.Println(resp.StatusCode)
+	// Output: 200
+}
+
+func TestAuthorizationDigest(t *testing.T) {
+	tests := []struct {
+		name       string
+		challenges []string
+		body       string
+	}{
+		{
+			name:       "md5",
+			challenges: []string{`Digest realm="test", qop="auth", nonce="NONCE", opaque="xyz"`},
+		},
+		{
+			name:       "sha-256",
+			challenges: []string{`Digest realm="test", qop="auth", algorithm=SHA-256, nonce="NONCE"`},
+		},
+		{
+			name:       "sha-512-256 session",
+			challenges: []string{`Digest realm="test", qop="auth", algorithm=SHA-512-256-sess, nonce="NONCE"`},
+		},
+		{
+			name:       "md5 session",
+			challenges: []string{`Digest realm="test", qop="auth", algorithm=MD5-sess, nonce="NONCE"`},
+		},
+		{
+			name:       "auth-int",
+			challenges: []string{`Digest realm="test", qop="auth-int", algorithm=SHA-256, nonce="NONCE"`},
+			body:       "payload",
+		},
+		{
+			name:       "without qop",
+			challenges: []string{`Digest realm="test", nonce="NONCE"`},
+		},
+		{
+			name:       "userhash",
+			challenges: []string{`Digest realm="test", qop="auth", algorithm=SHA-256, nonce="NONCE", userhash=true`},
+		},
+		{
+			name: "strongest algorithm",
+			challenges: []string{
+				`Basic realm="test"`,
+				`Digest realm="test", qop="auth", algorithm=MD5, nonce="NONCE"`,
+				`Digest realm="test", qop="auth", algorithm=SHA-256, nonce="NONCE"`,
+				`Digest realm="test", qop="auth", algorithm=UNKNOWN, nonce="NONCE"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &digestServer{challenges: tt.challenges, nonce: "abc"}
+			c := httpc.NewClient(srv, httpc.AuthorizationDigest("Mufasa", "Circle of Life"))
+
+			for range 2 {
+				r, err := http.NewRequest(http.MethodPost, "http://localhost/dir/index.html?q=1", strings.NewReader(tt.body))
+				if err != nil {
+					t.Fatalf("Cannot create request: %v", err)
+				}
+
+				resp, err := c.Do(r)
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				resp.Body.Close()
+				checkStatus(t, resp, http.StatusOK)
+			}
+
+			// The second request is authorized with the cached challenge.
+			if srv.requests != 3 {
+				t.Errorf("Expected 3 requests but got %d", srv.requests)
+			}
+			wantNCs := []string{"00000001", "00000002"}
+			if strings.Contains(tt.name, "without qop") {
+				wantNCs = []string{"", ""}
+			}
+			if !reflect.DeepEqual(srv.ncs, wantNCs) {
+				t.Errorf("Expected nonce counts %q but got %q", wantNCs, srv.ncs)
+			}
+			for _, b := range srv.bodies {
+				if b != tt.body {
+					t.Errorf("Expected body %q but got %q", tt.body, b)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthorizationDigest_staleNonce(t *testing.T) {
+	srv := &digestServer{
+		challenges: []string{`Digest realm="test", qop="auth", nonce="NONCE"`},
+		nonce:      "first",
+	}
+	c := httpc.NewClient(srv, httpc.AuthorizationDigest("Mufasa", "Circle of Life"))
+
+	if err := doGet(t, c, context.Background(), "http://localhost"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	srv.nonce = "second"
+	if err := doGet(t, c, context.Background(), "http://localhost"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if srv.requests != 4 {
+		t.Errorf("Expected 4 requests but got %d", srv.requests)
+	}
+	if want := []string{"00000001", "00000001"}; !reflect.DeepEqual(srv.ncs, want) {
+		t.Errorf("Expected nonce counts %q but got %q", want, srv.ncs)
+	}
+}
+
+func TestAuthorizationDigest_rejected(t *testing.T) {
+	srv := &digestServer{
+		challenges: []string{`Digest realm="test", qop="auth", nonce="NONCE"`},
+		nonce:      "abc",
+	}
+	c := httpc.NewClient(srv, httpc.AuthorizationDigest("Mufasa", "wrong"))
+
+	for i, wantRequests := range []int{2, 3} {
+		r, err := http.NewRequest(http.MethodGet, "http://localhost", http.NoBody)
+		if err != nil {
+			t.Fatalf("Cannot create request: %v", err)
+		}
+		resp, err := c.Do(r)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		resp.Body.Close()
+
+		checkStatus(t, resp, http.StatusUnauthorized)
+		if srv.requests != wantRequests {
+			t.Errorf("Expected %d requests after request %d but got %d", wantRequests, i+1, srv.requests)
+		}
+	}
+}
+
+func TestAuthorizationDigest_bodyNotRewindable(t *testing.T) {
+	srv := &digestServer{
+		challenges: []string{`Digest realm="test", qop="auth", nonce="NONCE"`},
+		nonce:      "abc",
+	}
+	c := httpc.NewClient(srv, httpc.AuthorizationDigest("Mufasa", "Circle of Life"))
+
+	r, err := http.NewRequest(http.MethodPost, "http://localhost", io.NopCloser(strings.NewReader("payload")))
+	if err != nil {
+		t.Fatalf("Cannot create request: %v", err)
+	}
+	resp, err := c.Do(r)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	checkStatus(t, resp, http.StatusUnauthorized)
+	if srv.requests != 1 {
+		t.Errorf("Expected 1 request but got %d", srv.requests)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"net/http"
 	"runtime"
 	"strings"
@@ -53,7 +54,7 @@ func (c *WTC) Fetch(ctx context.Context) (string, error) {
 }
 
 // This simple application calls the WhatTheCommit API and demonstrates how to
-// set up some common client options and prepare custom middlewares.
+// set up some common client options and log the requests.
 func main() {
 	client := httpc.NewClient(
 		http.DefaultClient,
@@ -61,7 +62,9 @@ func main() {
 		httpc.StripSlashes(true),
 		httpc.UserAgent(userAgent),
 		httpc.Timeout(10*time.Second),
-		clientLogger(),
+		httpc.Log(slog.Default(), httpc.LogOptions{
+			Headers: []string{"User-Agent", "Content-Type"},
+		}),
 	)
 
 	// Make a call to the WhatTheCommit API for a cool commit message.
@@ -72,32 +75,3 @@ func main() {
 	}
 	log.Printf("Commit message: %q", strings.TrimSpace(string(commit)))
 }
-
-func clientLogger() httpc.MiddlewareFunc {
-	return func(next httpc.DoerFunc) httpc.DoerFunc {
-		return httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
-			var sb strings.Builder
-			sb.WriteString("Requesting " + r.URL.String() + "\n")
-			for k, vv := range r.Header {
-				sb.WriteString(k + ":\t" + strings.Join(vv, ","))
-			}
-			sb.WriteString("\n")
-
-			start := time.Now()
-			resp, err := next(r)
-			if err != nil {
-				return nil, err
-			}
-
-			took := time.Since(start)
-			txt := fmt.Sprintf("Received [%d] %s %s in %v\n", resp.StatusCode, r.Method, r.URL, took)
-			sb.WriteString(txt)
-			for k, vv := range resp.Header {
-				sb.WriteString(k + ":\t" + strings.Join(vv, ",") + "\n")
-			}
-
-			log.Print(sb.String())
-			return resp, nil
-		})
-	}
-}
@@ -102,14 +102,20 @@ type RoundTripper struct {
 	base        http.RoundTripper
 	chain       DoerFunc
 	middlewares []MiddlewareFunc
+	named       []*named // The named middlewares, nil for the unnamed ones.
 }
 
 // NewRoundTripper returns a new middleware round tripper.
 func NewRoundTripper(rt http.RoundTripper, mws ...MiddlewareFunc) *RoundTripper {
+	return newRoundTripper(rt, mws, namedIn(mws))
+}
+
+func newRoundTripper(rt http.RoundTripper, mws []MiddlewareFunc, ns []*named) *RoundTripper {
 	return &RoundTripper{
 		base:        rt,
-		chain:       applyMiddlewares(rt.RoundTrip, mws...),
+		chain:       applyMiddlewares(withRequestMiddlewares(rt.RoundTrip), mws...),
 		middlewares: mws,
+		named:       ns,
 	}
 }
 
@@ -125,7 +131,7 @@ func (t *RoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
 // and the new ones are (n1, n2), the resulting chain will be
 // (p1, p2, p3, n1, n2).
 func (t *RoundTripper) With(mws ...MiddlewareFunc) *RoundTripper {
-	return NewRoundTripper(t.base, slices.Concat(t.middlewares, mws)...)
+	return newRoundTripper(t.base, slices.Concat(t.middlewares, mws), slices.Concat(t.named, namedIn(mws)))
 }
 
 // MiddlewareFunc is a function that wraps a [DoerFunc].
@@ -139,11 +145,13 @@ type MiddlewareFunc func(next DoerFunc) DoerFunc
 //
 // This client enables custom processing and behavior to be applied to HTTP
 // requests through middleware functions. Middleware can be added during
-// construction or via methods like With to modify the client's behavior.
+// construction or via methods like With to modify the client's behavior, or
+// for a single request with [WithRequestMiddleware].
 type Client struct {
 	base        Doer
 	chain       Doer
 	middlewares []MiddlewareFunc
+	named       []*named // The named middlewares, nil for the unnamed ones.
 }
 
 // NewClient creates a new [Client] with the provided middlewares.
@@ -153,10 +161,15 @@ type Client struct {
 // customize its behavior. The resulting client incorporates these middlewares
 // into its request processing pipeline.
 func NewClient(doer Doer, mws ...MiddlewareFunc) *Client {
+	return newClient(doer, mws, namedIn(mws))
+}
+
+func newClient(doer Doer, mws []MiddlewareFunc, ns []*named) *Client {
 	return &Client{
 		base:        doer,
-		chain:       applyMiddlewares(doer.Do, mws...),
+		chain:       applyMiddlewares(withRequestMiddlewares(doer.Do), mws...),
 		middlewares: mws,
+		named:       ns,
 	}
 }
 
@@ -176,7 +189,7 @@ func (c *Client) Do(r *http.Request) (*http.Response, error) {
 // middleware chain (p1, p2, p3) and the provided middlewares are (n1, n2),
 // the resulting chain will be: (p1, p2, p3, n1, n2).
 func (c *Client) With(mws ...MiddlewareFunc) *Client {
-	return NewClient(c.base, slices.Concat(c.middlewares, mws)...)
+	return newClient(c.base, slices.Concat(c.middlewares, mws), slices.Concat(c.named, namedIn(mws)))
 }
 
 // applyMiddlewares constructs a middleware chain that executes in the order
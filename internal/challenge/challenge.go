@@ -0,0 +1,159 @@
+// Package challenge parses the authentication challenges of the
+// WWW-Authenticate header, as defined in RFC 9110 Section 11.
+package challenge
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Challenge is an authentication challenge.
+type Challenge struct {
+	Scheme  string            // The auth scheme, e.g. "Bearer".
+	Params  map[string]string // The auth params, keyed by the lowercase name.
+	Token68 string            // The token68 form of the credentials, if any.
+}
+
+// Find returns the first challenge of the header with the scheme, compared
+// case-insensitively, and reports whether it was found.
+func Find(h http.Header, scheme string) (Challenge, bool) {
+	for _, c := range Parse(h.Values("WWW-Authenticate")) {
+		if strings.EqualFold(c.Scheme, scheme) {
+			return c, true
+		}
+	}
+	return Challenge{}, false
+}
+
+// Parse returns the challenges of the header values. Malformed parts are
+// skipped.
+func Parse(values []string) []Challenge {
+	var challenges []Challenge
+	for _, v := range values {
+		p := &parser{s: v}
+		for {
+			c, ok := p.challenge()
+			if !ok {
+				break
+			}
+			challenges = append(challenges, c)
+		}
+	}
+	return challenges
+}
+
+type parser struct {
+	s string
+	i int
+}
+
+func (p *parser) challenge() (Challenge, bool) {
+	p.skip(" \t,")
+	scheme := p.token()
+	if scheme == "" {
+		return Challenge{}, false
+	}
+
+	c := Challenge{Scheme: scheme, Params: make(map[string]string)}
+	p.skip(" \t")
+
+	// The token68 ends with the padding and is followed by a comma or the end.
+	if t68, ok := p.token68(); ok {
+		c.Token68 = t68
+		return c, true
+	}
+
+	for {
+		start := p.i
+		p.skip(" \t,")
+		name := p.token()
+		p.skip(" \t")
+		if name == "" || !p.consume('=') {
+			// The next challenge or the end.
+			p.i = start
+			return c, true
+		}
+		p.skip(" \t")
+
+		var value string
+		if p.peek() == '"' {
+			value = p.quoted()
+		} else {
+			value = p.token()
+		}
+		c.Params[strings.ToLower(name)] = value
+	}
+}
+
+func (p *parser) token68() (string, bool) {
+	start := p.i
+	for p.i < len(p.s) && isToken68(p.s[p.i]) {
+		p.i++
+	}
+	for p.i < len(p.s) && p.s[p.i] == '=' {
+		p.i++
+	}
+	end := p.i
+	p.skip(" \t")
+	if end > start && (p.i == len(p.s) || p.s[p.i] == ',') {
+		return p.s[start:end], true
+	}
+	p.i = start
+	return "", false
+}
+
+func (p *parser) token() string {
+	start := p.i
+	for p.i < len(p.s) && isToken(p.s[p.i]) {
+		p.i++
+	}
+	return p.s[start:p.i]
+}
+
+func (p *parser) quoted() string {
+	var b strings.Builder
+	p.i++ // The opening quote.
+	for p.i < len(p.s) {
+		c := p.s[p.i]
+		p.i++
+		switch {
+		case c == '"':
+			return b.String()
+		case c == '\\' && p.i < len(p.s):
+			b.WriteByte(p.s[p.i])
+			p.i++
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func (p *parser) skip(chars string) {
+	for p.i < len(p.s) && strings.IndexByte(chars, p.s[p.i]) >= 0 {
+		p.i++
+	}
+}
+
+func (p *parser) consume(c byte) bool {
+	if p.peek() == c {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) peek() byte {
+	if p.i < len(p.s) {
+		return p.s[p.i]
+	}
+	return 0
+}
+
+func isToken(c byte) bool {
+	return c > ' ' && c < 0x7f && !strings.ContainsRune("\"(),/:;<=>?@[\\]{}", rune(c))
+}
+
+func isToken68(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("-._~+/", c) >= 0
+}
@@ -0,0 +1,53 @@
+package challenge_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kraciasty/httpc/internal/challenge"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   []challenge.Challenge
+	}{
+		{
+			name:   "scheme only",
+			values: []string{"Negotiate"},
+			want:   []challenge.Challenge{{Scheme: "Negotiate", Params: map[string]string{}}},
+		},
+		{
+			name:   "params",
+			values: []string{`Bearer realm="example", error="invalid_token", error_description="The \"token\" expired"`},
+			want: []challenge.Challenge{{Scheme: "Bearer", Params: map[string]string{
+				"realm":             "example",
+				"error":             "invalid_token",
+				"error_description": `The "token" expired`,
+			}}},
+		},
+		{
+			name:   "multiple challenges",
+			values: []string{`Basic realm="a", Digest Realm=b, qop="auth,auth-int", Bearer`, "Newauth abc=="},
+			want: []challenge.Challenge{
+				{Scheme: "Basic", Params: map[string]string{"realm": "a"}},
+				{Scheme: "Digest", Params: map[string]string{"realm": "b", "qop": "auth,auth-int"}},
+				{Scheme: "Bearer", Params: map[string]string{}},
+				{Scheme: "Newauth", Params: map[string]string{}, Token68: "abc=="},
+			},
+		},
+		{
+			name:   "empty",
+			values: []string{"", " , "},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := challenge.Parse(tt.values); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected challenges %+v but got %+v", tt.want, got)
+			}
+		})
+	}
+}
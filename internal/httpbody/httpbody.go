@@ -0,0 +1,41 @@
+// Package httpbody provides the helpers for resending the request bodies and
+// discarding the response bodies shared by the middlewares.
+package httpbody
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Rewindable reports whether the request body can be sent more than once.
+func Rewindable(r *http.Request) bool {
+	return r.Body == nil || r.Body == http.NoBody || r.GetBody != nil
+}
+
+// Rewind returns a copy of the request with a fresh body.
+func Rewind(r *http.Request) (*http.Request, error) {
+	req := r.Clone(r.Context())
+	if r.Body == nil || r.Body == http.NoBody {
+		return req, nil
+	}
+
+	body, err := r.GetBody()
+	if err != nil {
+		return nil, fmt.Errorf("rewind body: %w", err)
+	}
+
+	req.Body = body
+	return req, nil
+}
+
+// Drain discards a bounded amount of the response body and closes it,
+// which allows the underlying connection to be reused.
+func Drain(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+	_ = resp.Body.Close()
+}
@@ -0,0 +1,57 @@
+package httpbody_test
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/kraciasty/httpc/internal/httpbody"
+)
+
+func TestRewindable(t *testing.T) {
+	tests := []struct {
+		name string
+		body io.Reader
+		drop bool // Drops the GetBody function.
+		want bool
+	}{
+		{name: "no body", want: true},
+		{name: "rewindable body", body: strings.NewReader("body"), want: true},
+		{name: "one-shot body", body: strings.NewReader("body"), drop: true, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := http.NewRequest(http.MethodPost, "http://localhost", tt.body)
+			if err != nil {
+				t.Fatalf("Cannot create request: %v", err)
+			}
+			if tt.drop {
+				r.GetBody = nil
+			}
+			if got := httpbody.Rewindable(r); got != tt.want {
+				t.Errorf("Expected rewindable %v but got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRewind(t *testing.T) {
+	r, err := http.NewRequest(http.MethodPost, "http://localhost", strings.NewReader("body"))
+	if err != nil {
+		t.Fatalf("Cannot create request: %v", err)
+	}
+	_, _ = io.ReadAll(r.Body)
+
+	req, err := httpbody.Rewind(r)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if req == r {
+		t.Errorf("Expected a copy of the request")
+	}
+	if b, _ := io.ReadAll(req.Body); string(b) != "body" {
+		t.Errorf("Expected body %q but got %q", "body", b)
+	}
+}
@@ -0,0 +1,152 @@
+package httpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+
+	"github.com/kraciasty/httpc/internal/httpbody"
+)
+
+// defaultMaxJSONBytes is the default limit of the decoded JSON bodies.
+const defaultMaxJSONBytes = 10 << 20
+
+// JSONOption configures the JSON helpers, such as [GetJSON] and [DoJSON].
+type JSONOption func(*jsonOptions)
+
+type jsonOptions struct {
+	disallowUnknownFields bool
+	maxBytes              int64
+}
+
+func newJSONOptions(opts []JSONOption) jsonOptions {
+	o := jsonOptions{maxBytes: defaultMaxJSONBytes}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// DisallowUnknownFields makes the decoding fail when the response has fields
+// that are not in the destination type, see
+// [json.Decoder.DisallowUnknownFields].
+func DisallowUnknownFields() JSONOption {
+	return func(o *jsonOptions) {
+		o.disallowUnknownFields = true
+	}
+}
+
+// MaxJSONBytes limits the size of the decoded response body to n bytes.
+// Larger bodies fail with [ErrResponseTooLarge]. Defaults to 10 MiB.
+func MaxJSONBytes(n int64) JSONOption {
+	return func(o *jsonOptions) {
+		if n > 0 {
+			o.maxBytes = n
+		}
+	}
+}
+
+// GetJSON sends a GET request to the URL with the doer and decodes the JSON
+// response body into a value of type T, see [DoJSON].
+func GetJSON[T any](ctx context.Context, d Doer, url string, opts ...JSONOption) (T, error) {
+	return doJSON[T](ctx, d, http.MethodGet, url, nil, opts)
+}
+
+// DoJSON sends a request with the body encoded as JSON to the URL with the
+// doer and decodes the JSON response body into a value of type Resp.
+//
+// The request has the Accept header set to application/json. Unless the body
+// is nil, or a nil pointer, map or slice, it is encoded with the Content-Type
+// header set to application/json and the GetBody function set, so that it can
+// be retried or redirected. Responses with a status code other than 2xx fail
+// with a [StatusError]. Responses with the 204 No Content status decode into
+// the zero value.
+//
+// The response body is always drained and closed.
+func DoJSON[Req, Resp any](ctx context.Context, d Doer, method, url string, body Req, opts ...JSONOption) (Resp, error) {
+	var data []byte
+	if !isNil(body) {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			var zero Resp
+			return zero, fmt.Errorf("encode json: %w", err)
+		}
+	}
+	return doJSON[Resp](ctx, d, method, url, data, opts)
+}
+
+// isNil reports whether the value is nil or a nil pointer, map or slice.
+func isNil(v any) bool {
+	if v == nil {
+		return true
+	}
+
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice:
+		return rv.IsNil()
+	default:
+		return false
+	}
+}
+
+func doJSON[T any](ctx context.Context, d Doer, method, url string, data []byte, opts []JSONOption) (T, error) {
+	var zero T
+
+	var body io.Reader = http.NoBody
+	if data != nil {
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return zero, fmt.Errorf("new request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := d.Do(req)
+	if err != nil {
+		return zero, err
+	}
+	return decodeJSON[T](resp, newJSONOptions(opts))
+}
+
+// decodeJSON decodes the response body into a value of type T and drains
+// and closes the body.
+func decodeJSON[T any](resp *http.Response, opts jsonOptions) (T, error) {
+	defer httpbody.Drain(resp)
+
+	var v T
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return v, newStatusError(resp)
+	}
+	if resp.StatusCode == http.StatusNoContent || resp.Body == nil {
+		return v, nil
+	}
+
+	if resp.ContentLength > opts.maxBytes {
+		return v, fmt.Errorf("%w: content length %d exceeds %d bytes",
+			ErrResponseTooLarge, resp.ContentLength, opts.maxBytes)
+	}
+
+	dec := json.NewDecoder(&limitedBody{
+		ReadCloser: resp.Body,
+		remaining:  opts.maxBytes,
+		err:        ErrResponseTooLarge,
+	})
+	if opts.disallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+
+	if err := dec.Decode(&v); err != nil {
+		var zero T
+		return zero, fmt.Errorf("decode json: %w", err)
+	}
+	return v, nil
+}
@@ -0,0 +1,178 @@
+package httpc_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/kraciasty/httpc"
+)
+
+type user struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func ExampleGetJSON() {
+	doer := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"id":1,"name":"Gopher"}`)),
+		}, nil
+	})
+
+	u, err := httpc.GetJSON[user](context.Background(), doer, "http://stuff.local/users/1")
+	fmt.Println(u.Name, err)
+	// Output: Gopher <nil>
+}
+
+func TestDoJSON(t *testing.T) {
+	srv := setupServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/users", http.StatusTemporaryRedirect)
+			return
+		}
+
+		checkHeader(t, r, "Accept", "application/json")
+		checkHeader(t, r, "Content-Type", "application/json")
+
+		body, _ := io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"id":1,"name":%q}`, strings.TrimSuffix(strings.TrimPrefix(string(body), `{"id":0,"name":"`), `"}`))
+	}))
+
+	got, err := httpc.DoJSON[user, user](t.Context(), srv.Client(), http.MethodPost, srv.URL+"/redirect", user{Name: "Gopher"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if want := (user{ID: 1, Name: "Gopher"}); got != want {
+		t.Errorf("Expected %+v but got %+v", want, got)
+	}
+}
+
+func TestDoJSON_noBody(t *testing.T) {
+	doer := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+		checkHeader(t, r, "Content-Type", "")
+		if r.Body != http.NoBody {
+			t.Errorf("Expected no request body")
+		}
+		return &http.Response{StatusCode: http.StatusNoContent, Body: http.NoBody}, nil
+	})
+
+	got, err := httpc.DoJSON[any, *user](t.Context(), doer, http.MethodDelete, "http://localhost/users/1", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got != nil {
+		t.Errorf("Expected zero value but got %+v", got)
+	}
+
+	tests := map[string]func() error{
+		"nil pointer": func() error {
+			_, err := httpc.DoJSON[*user, any](t.Context(), doer, http.MethodDelete, "http://localhost/users/1", nil)
+			return err
+		},
+		"nil map": func() error {
+			_, err := httpc.DoJSON[map[string]any, any](t.Context(), doer, http.MethodDelete, "http://localhost/users/1", nil)
+			return err
+		},
+		"nil slice": func() error {
+			_, err := httpc.DoJSON[[]user, any](t.Context(), doer, http.MethodDelete, "http://localhost/users/1", nil)
+			return err
+		},
+		"nil pointer in interface": func() error {
+			_, err := httpc.DoJSON[any, any](t.Context(), doer, http.MethodDelete, "http://localhost/users/1", (*user)(nil))
+			return err
+		},
+	}
+	for name, do := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := do(); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestGetJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		length  int64
+		opts    []httpc.JSONOption
+		want    user
+		wantErr error
+	}{
+		{
+			name:   "ok",
+			status: http.StatusOK,
+			body:   `{"id":1,"name":"Gopher","extra":true}`,
+			want:   user{ID: 1, Name: "Gopher"},
+		},
+		{
+			name:    "unknown fields",
+			status:  http.StatusOK,
+			body:    `{"id":1,"name":"Gopher","extra":true}`,
+			opts:    []httpc.JSONOption{httpc.DisallowUnknownFields()},
+			wantErr: errors.New(`decode json: json: unknown field "extra"`),
+		},
+		{
+			name:    "status",
+			status:  http.StatusNotFound,
+			body:    `not found`,
+			wantErr: httpc.ErrUnexpectedStatus,
+		},
+		{
+			name:    "too large",
+			status:  http.StatusOK,
+			body:    `{"id":1,"name":"Gopher"}`,
+			opts:    []httpc.JSONOption{httpc.MaxJSONBytes(10)},
+			wantErr: httpc.ErrResponseTooLarge,
+		},
+		{
+			name:    "too large content length",
+			status:  http.StatusOK,
+			body:    `{"id":1,"name":"Gopher"}`,
+			length:  24,
+			opts:    []httpc.JSONOption{httpc.MaxJSONBytes(10)},
+			wantErr: httpc.ErrResponseTooLarge,
+		},
+		{
+			name:    "invalid",
+			status:  http.StatusOK,
+			body:    `<html>`,
+			wantErr: errors.New("decode json: invalid character '<' looking for beginning of value"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := &trackedBody{Reader: strings.NewReader(tt.body)}
+			doer := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+				checkMethod(t, r, http.MethodGet)
+				checkHeader(t, r, "Accept", "application/json")
+				return &http.Response{StatusCode: tt.status, ContentLength: tt.length, Body: body}, nil
+			})
+
+			got, err := httpc.GetJSON[user](t.Context(), doer, "http://localhost", tt.opts...)
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("Unexpected error: %v", err)
+			case tt.wantErr != nil && !errors.Is(err, tt.wantErr) && fmt.Sprint(err) != tt.wantErr.Error():
+				t.Fatalf("Expected error %v but got: %v", tt.wantErr, err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Expected %+v but got %+v", tt.want, got)
+			}
+			if !body.closed {
+				t.Errorf("Expected body to be closed")
+			}
+		})
+	}
+}
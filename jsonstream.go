@@ -0,0 +1,140 @@
+package httpc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"mime"
+	"net/http"
+)
+
+// recordSeparator starts the records of an RFC 7464 JSON text sequence.
+const recordSeparator = 0x1e
+
+// JSONStreamError is yielded by [StreamJSON] for a record that cannot be
+// decoded. It carries the position of the record in the stream.
+type JSONStreamError struct {
+	Line   int   // The line of the record start, starting at 1.
+	Offset int64 // The byte offset of the error in the stream.
+	Err    error // The decoding error.
+}
+
+// Error returns a string representation of the error with the position.
+func (e *JSONStreamError) Error() string {
+	return fmt.Sprintf("decode json stream: line %d, offset %d: %v", e.Line, e.Offset, e.Err)
+}
+
+// Unwrap returns the decoding error.
+func (e *JSONStreamError) Unwrap() error {
+	return e.Err
+}
+
+// StreamJSON returns an iterator over the JSON records of a streaming
+// response, decoded incrementally into values of type T.
+//
+// Responses with the application/json-seq content type are read as RFC 7464
+// JSON text sequences, others as newline-delimited JSON, skipping the blank
+// lines. Records larger than the [MaxJSONBytes] limit stop the iteration with
+// [ErrResponseTooLarge]. Records that cannot be decoded yield a
+// [JSONStreamError] and the iteration can continue with the next record.
+// Responses with a status code other than 2xx yield a [StatusError].
+//
+// The response body is closed when the iteration ends or stops early.
+func StreamJSON[T any](resp *http.Response, opts ...JSONOption) iter.Seq2[T, error] {
+	o := newJSONOptions(opts)
+
+	return func(yield func(T, error) bool) {
+		var zero T
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			yield(zero, newStatusError(resp))
+			return
+		}
+		if resp.Body == nil {
+			return
+		}
+		defer resp.Body.Close()
+
+		sep := byte('\n')
+		if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == "application/json-seq" {
+			sep = recordSeparator
+		}
+
+		sc := bufio.NewScanner(resp.Body)
+		sc.Buffer(make([]byte, 0, min(4<<10, o.maxBytes+1)), int(o.maxBytes)+1)
+		sc.Split(splitRecords(sep))
+
+		line, offset := 1, int64(0)
+		for sc.Scan() {
+			record := sc.Bytes()
+			start, startLine := offset, line
+			offset += int64(len(record)) + 1
+			line += bytes.Count(record, []byte{'\n'})
+			if sep == '\n' {
+				line++
+			}
+
+			trimmed := bytes.TrimSpace(record)
+			if len(trimmed) == 0 {
+				continue
+			}
+			start += int64(bytes.Index(record, trimmed))
+
+			var v T
+			if err := decodeRecord(trimmed, &v, o); err != nil {
+				var syntaxErr *json.SyntaxError
+				var typeErr *json.UnmarshalTypeError
+				switch {
+				case errors.As(err, &syntaxErr):
+					start += syntaxErr.Offset
+				case errors.As(err, &typeErr):
+					start += typeErr.Offset
+				}
+				if !yield(zero, &JSONStreamError{Line: startLine, Offset: start, Err: err}) {
+					return
+				}
+				continue
+			}
+			if !yield(v, nil) {
+				return
+			}
+		}
+
+		if err := sc.Err(); err != nil {
+			if errors.Is(err, bufio.ErrTooLong) {
+				err = fmt.Errorf("%w: record exceeds %d bytes", ErrResponseTooLarge, o.maxBytes)
+			}
+			yield(zero, &JSONStreamError{Line: line, Offset: offset, Err: err})
+		}
+	}
+}
+
+func decodeRecord(data []byte, v any, opts jsonOptions) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if opts.disallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("unexpected data after the json value")
+	}
+	return nil
+}
+
+// splitRecords is a [bufio.SplitFunc] for the records ending with the
+// separator. The last record does not need to end with the separator.
+func splitRecords(sep byte) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		if i := bytes.IndexByte(data, sep); i >= 0 {
+			return i + 1, data[:i], nil
+		}
+		if atEOF && len(data) > 0 {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	}
+}
@@ -0,0 +1,149 @@
+package httpc_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kraciasty/httpc"
+)
+
+func ExampleStreamJSON() {
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/x-ndjson"}},
+		Body:       io.NopCloser(strings.NewReader("{\"id\":1,\"name\":\"a\"}\n{\"id\":2,\"name\":\"b\"}\n")),
+	}
+
+	for u, err := range httpc.StreamJSON[user](resp) {
+		if err != nil {
+			break
+		}
+		fmt.Println(u.ID, u.Name)
+	}
+	// Output:
+	// 1 a
+	// 2 b
+}
+
+func TestStreamJSON(t *testing.T) {
+	type result struct {
+		ID     int
+		Line   int
+		Offset int64
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		opts        []httpc.JSONOption
+		want        []result
+		wantErr     error
+	}{
+		{
+			name: "ndjson",
+			body: "{\"id\":1}\r\n\n  \n{\"id\":2}\n{\"id\":3}",
+			want: []result{{ID: 1}, {ID: 2}, {ID: 3}},
+		},
+		{
+			name:        "json-seq",
+			contentType: "application/json-seq",
+			body:        "\x1e{\"id\":1}\n\x1e{\n\"id\":2\n}\n\x1e{\"id\":3}\n",
+			want:        []result{{ID: 1}, {ID: 2}, {ID: 3}},
+		},
+		{
+			name: "decode errors",
+			body: "{\"id\":1}\n{\"id\":}\n{\"id\":3}\n{\"id\":\"x\"}\n{\"id\":5} {}\n",
+			want: []result{
+				{ID: 1},
+				{Line: 2, Offset: 16},
+				{ID: 3},
+				{Line: 4, Offset: 35},
+				{Line: 5, Offset: 37},
+			},
+		},
+		{
+			name:        "json-seq decode error",
+			contentType: "application/json-seq; charset=utf-8",
+			body:        "\x1e{\"id\":1}\n\x1e{\n\"id\":\n}\n",
+			want:        []result{{ID: 1}, {Line: 2, Offset: 20}},
+		},
+		{
+			name: "unknown fields",
+			body: "{\"id\":1,\"extra\":true}\n",
+			opts: []httpc.JSONOption{httpc.DisallowUnknownFields()},
+			want: []result{{Line: 1, Offset: 0}},
+		},
+		{
+			name:    "record too large",
+			body:    "{\"id\":1}\n{\"id\":2,\"name\":\"too large\"}\n{\"id\":3}\n",
+			opts:    []httpc.JSONOption{httpc.MaxJSONBytes(16)},
+			want:    []result{{ID: 1}},
+			wantErr: httpc.ErrResponseTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := &trackedBody{Reader: strings.NewReader(tt.body)}
+			resp := &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {tt.contentType}},
+				Body:       body,
+			}
+
+			var got []result
+			var gotErr error
+			for u, err := range httpc.StreamJSON[user](resp, tt.opts...) {
+				var streamErr *httpc.JSONStreamError
+				switch {
+				case errors.Is(err, httpc.ErrResponseTooLarge):
+					gotErr = err
+				case errors.As(err, &streamErr):
+					got = append(got, result{Line: streamErr.Line, Offset: streamErr.Offset})
+				case err != nil:
+					t.Fatalf("Unexpected error: %v", err)
+				default:
+					got = append(got, result{ID: u.ID})
+				}
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected results %+v but got %+v", tt.want, got)
+			}
+			if !errors.Is(gotErr, tt.wantErr) {
+				t.Errorf("Expected error %v but got: %v", tt.wantErr, gotErr)
+			}
+			if !body.closed {
+				t.Errorf("Expected body to be closed")
+			}
+		})
+	}
+}
+
+func TestStreamJSON_stop(t *testing.T) {
+	body := &trackedBody{Reader: strings.NewReader("{\"id\":1}\n{\"id\":2}\n")}
+	resp := &http.Response{StatusCode: http.StatusOK, Body: body}
+
+	for range httpc.StreamJSON[user](resp) {
+		break
+	}
+	if !body.closed {
+		t.Errorf("Expected body to be closed")
+	}
+}
+
+func TestStreamJSON_status(t *testing.T) {
+	resp := &http.Response{StatusCode: http.StatusBadGateway, Body: io.NopCloser(strings.NewReader("bad gateway"))}
+
+	for _, err := range httpc.StreamJSON[user](resp) {
+		if !errors.Is(err, httpc.ErrUnexpectedStatus) {
+			t.Errorf("Expected httpc.ErrUnexpectedStatus but got: %v", err)
+		}
+	}
+}
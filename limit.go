@@ -0,0 +1,123 @@
+package httpc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+var (
+	// ErrResponseTooLarge indicates that the response body exceeded the limit
+	// set with [MaxResponseBytes].
+	ErrResponseTooLarge = errors.New("response too large")
+
+	// ErrRequestTooLarge indicates that the request body exceeded the limit
+	// set with [MaxRequestBytes].
+	ErrRequestTooLarge = errors.New("request too large")
+)
+
+// MaxResponseBytes limits the size of the response bodies to n bytes.
+//
+// Responses with a Content-Length above the limit are rejected up-front with
+// [ErrResponseTooLarge] and their body is closed. Otherwise reading the body
+// beyond the limit fails with [ErrResponseTooLarge], unlike [io.LimitReader]
+// which silently truncates the data.
+//
+// The middleware is not applied if the limit is below or equal to zero.
+func MaxResponseBytes(n int64) MiddlewareFunc {
+	if n <= 0 {
+		return nil
+	}
+
+	return func(next DoerFunc) DoerFunc {
+		return func(r *http.Request) (*http.Response, error) {
+			resp, err := next(r)
+			if err != nil || resp.Body == nil {
+				return resp, err
+			}
+
+			if resp.ContentLength > n {
+				_ = resp.Body.Close()
+				return nil, fmt.Errorf("%w: content length %d exceeds %d bytes",
+					ErrResponseTooLarge, resp.ContentLength, n)
+			}
+
+			resp.Body = &limitedBody{
+				ReadCloser: resp.Body,
+				remaining:  n,
+				err:        ErrResponseTooLarge,
+			}
+			return resp, nil
+		}
+	}
+}
+
+// MaxRequestBytes limits the size of the request bodies to n bytes.
+//
+// Requests with a Content-Length above the limit are rejected with
+// [ErrRequestTooLarge] before they are sent. Otherwise reading the body beyond
+// the limit fails with [ErrRequestTooLarge], which aborts the request.
+//
+// The middleware is not applied if the limit is below or equal to zero.
+func MaxRequestBytes(n int64) MiddlewareFunc {
+	if n <= 0 {
+		return nil
+	}
+
+	return func(next DoerFunc) DoerFunc {
+		return func(r *http.Request) (*http.Response, error) {
+			if r.Body == nil || r.Body == http.NoBody {
+				return next(r)
+			}
+
+			if r.ContentLength > n {
+				_ = r.Body.Close()
+				return nil, fmt.Errorf("%w: content length %d exceeds %d bytes",
+					ErrRequestTooLarge, r.ContentLength, n)
+			}
+
+			r.Body = &limitedBody{ReadCloser: r.Body, remaining: n, err: ErrRequestTooLarge}
+			if getBody := r.GetBody; getBody != nil {
+				r.GetBody = func() (io.ReadCloser, error) {
+					body, err := getBody()
+					if err != nil {
+						return nil, err
+					}
+					return &limitedBody{ReadCloser: body, remaining: n, err: ErrRequestTooLarge}, nil
+				}
+			}
+
+			return next(r)
+		}
+	}
+}
+
+// limitedBody fails with the error when more than the remaining bytes are
+// available to read.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	err       error
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	// Read one byte more than allowed to detect an oversized body.
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		n = int(b.remaining)
+		b.remaining = 0
+		return n, b.err
+	}
+
+	b.remaining -= int64(n)
+	return n, err
+}
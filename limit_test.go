@@ -0,0 +1,172 @@
+package httpc_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/kraciasty/httpc"
+)
+
+func TestMaxResponseBytes(t *testing.T) {
+	tests := []struct {
+		name          string
+		limit         int64
+		body          string
+		contentLength int64
+		wantErr       bool
+		wantReadErr   bool
+	}{
+		{
+			name:          "within limit",
+			limit:         10,
+			body:          "0123456789",
+			contentLength: 10,
+		},
+		{
+			name:          "unknown length within limit",
+			limit:         10,
+			body:          "0123456789",
+			contentLength: -1,
+		},
+		{
+			name:          "content length exceeds limit",
+			limit:         5,
+			body:          "0123456789",
+			contentLength: 10,
+			wantErr:       true,
+		},
+		{
+			name:          "unknown length exceeds limit",
+			limit:         5,
+			body:          "0123456789",
+			contentLength: -1,
+			wantReadErr:   true,
+		},
+		{
+			name:          "no limit",
+			body:          "0123456789",
+			contentLength: -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := &trackedBody{Reader: strings.NewReader(tt.body)}
+			doer := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode:    http.StatusOK,
+					ContentLength: tt.contentLength,
+					Body:          body,
+				}, nil
+			})
+
+			c := httpc.NewClient(doer, httpc.MaxResponseBytes(tt.limit))
+			req, err := http.NewRequest(http.MethodGet, "http://localhost", http.NoBody)
+			if err != nil {
+				t.Fatalf("Cannot create request: %v", err)
+			}
+
+			resp, err := c.Do(req)
+			if tt.wantErr {
+				if !errors.Is(err, httpc.ErrResponseTooLarge) {
+					t.Errorf("Expected httpc.ErrResponseTooLarge but got: %v", err)
+				}
+				if !body.closed {
+					t.Errorf("Expected rejected body to be closed")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			defer resp.Body.Close()
+
+			got, err := io.ReadAll(resp.Body)
+			if tt.wantReadErr {
+				if !errors.Is(err, httpc.ErrResponseTooLarge) {
+					t.Errorf("Expected httpc.ErrResponseTooLarge but got: %v", err)
+				}
+				if int64(len(got)) != tt.limit {
+					t.Errorf("Expected %d bytes before the error but got %d", tt.limit, len(got))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if string(got) != tt.body {
+				t.Errorf("Expected body %q but got %q", tt.body, got)
+			}
+		})
+	}
+}
+
+func TestMaxRequestBytes(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   int64
+		body    func() io.Reader
+		wantErr bool
+	}{
+		{
+			name:  "within limit",
+			limit: 10,
+			body:  func() io.Reader { return strings.NewReader("0123456789") },
+		},
+		{
+			name:  "unknown length within limit",
+			limit: 10,
+			body:  func() io.Reader { return io.MultiReader(strings.NewReader("0123456789")) },
+		},
+		{
+			name:    "content length exceeds limit",
+			limit:   5,
+			body:    func() io.Reader { return bytes.NewReader([]byte("0123456789")) },
+			wantErr: true,
+		},
+		{
+			name:    "unknown length exceeds limit",
+			limit:   5,
+			body:    func() io.Reader { return io.MultiReader(strings.NewReader("0123456789")) },
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doer := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+				if _, err := io.ReadAll(r.Body); err != nil {
+					return nil, err
+				}
+				if r.GetBody != nil {
+					body, _ := r.GetBody()
+					if _, err := io.ReadAll(body); err != nil {
+						return nil, err
+					}
+				}
+				return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+			})
+
+			c := httpc.NewClient(doer, httpc.MaxRequestBytes(tt.limit))
+			req, err := http.NewRequest(http.MethodPost, "http://localhost", tt.body())
+			if err != nil {
+				t.Fatalf("Cannot create request: %v", err)
+			}
+
+			_, err = c.Do(req)
+			if tt.wantErr {
+				if !errors.Is(err, httpc.ErrRequestTooLarge) {
+					t.Errorf("Expected httpc.ErrRequestTooLarge but got: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package httpc
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"mime"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// redacted replaces the values of sensitive headers and query parameters.
+const redacted = "REDACTED"
+
+// defaultRedactHeaders are the headers that are always redacted by [Log].
+var defaultRedactHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+}
+
+// LogOptions configures the [Log] middleware.
+type LogOptions struct {
+	// Level returns the log level for the request outcome.
+	// Defaults to [DefaultLogLevel].
+	Level func(*http.Response, error) slog.Level
+
+	// Headers lists the request and response headers to log.
+	// No headers are logged when empty.
+	Headers []string
+
+	// RedactHeaders lists additional headers with values to redact.
+	// The Authorization, Proxy-Authorization, Cookie and Set-Cookie headers
+	// are always redacted.
+	RedactHeaders []string
+
+	// RedactQuery lists the URL query parameters with values to redact.
+	RedactQuery []string
+
+	// BodyLimit is the maximum number of request and response body bytes to
+	// log. Bodies are not logged when zero.
+	//
+	// The response body is peeked before the response is returned, which
+	// blocks until the limit or the end of the body is reached. The bodies of
+	// the streamed event-stream and NDJSON responses are not logged.
+	BodyLimit int64
+
+	// SuccessSampling is the fraction of successful requests to log, between
+	// 0 and 1. Failed requests are always logged. Zero logs all requests.
+	SuccessSampling float64
+}
+
+// DefaultLogLevel is the default [LogOptions] Level. It logs transport errors
+// and 5xx responses at the error level, 4xx responses at the warn level and
+// other responses at the info level.
+func DefaultLogLevel(resp *http.Response, err error) slog.Level {
+	switch {
+	case err != nil || resp.StatusCode >= http.StatusInternalServerError:
+		return slog.LevelError
+	case resp.StatusCode >= http.StatusBadRequest:
+		return slog.LevelWarn
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// Log is a middleware that logs the requests with the provided logger.
+//
+// Each request is logged with its method, URL, status code, duration, request
+// and response sizes taken from the Content-Length, and the error if any.
+// The request ID is logged when set by the [RequestID] middleware earlier in
+// the chain.
+// Headers are logged only when allow-listed and sensitive headers and query
+// parameters are redacted, see [LogOptions].
+//
+// Captured bodies are peeked, so that they can still be read by the server
+// and the caller. Extra attributes can be added for a single request with the
+// [LogAttrs] option.
+func Log(logger *slog.Logger, opts LogOptions) MiddlewareFunc {
+	if logger == nil {
+		return nil
+	}
+	if opts.Level == nil {
+		opts.Level = DefaultLogLevel
+	}
+
+	redact := slices.Concat(defaultRedactHeaders, opts.RedactHeaders)
+	for i, h := range redact {
+		redact[i] = http.CanonicalHeaderKey(h)
+	}
+
+	return func(next DoerFunc) DoerFunc {
+		return func(r *http.Request) (*http.Response, error) {
+			var reqBody []byte
+			if opts.BodyLimit > 0 {
+				reqBody = peekRequestBody(r, opts.BodyLimit)
+			}
+
+			start := time.Now()
+			resp, err := next(r)
+			took := time.Since(start)
+
+			level := opts.Level(resp, err)
+			success := err == nil && resp.StatusCode < http.StatusBadRequest
+			if success && opts.SuccessSampling > 0 && opts.SuccessSampling < 1 &&
+				rand.Float64() >= opts.SuccessSampling {
+				return resp, err
+			}
+
+			ctx := r.Context()
+			if !logger.Enabled(ctx, level) {
+				return resp, err
+			}
+
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("url", redactURL(r, opts.RedactQuery)),
+				slog.Duration("duration", took),
+				slog.Int64("request_size", r.ContentLength),
+			}
+			if id, ok := RequestIDFromContext(ctx); ok {
+				attrs = append(attrs, slog.String("request_id", id))
+			}
+			if len(opts.Headers) > 0 {
+				attrs = append(attrs, headersAttr("request_headers", r.Header, opts.Headers, redact))
+			}
+			if reqBody != nil {
+				attrs = append(attrs, slog.String("request_body", string(reqBody)))
+			}
+
+			if err != nil {
+				attrs = append(attrs, slog.Any("error", err))
+			}
+			if resp != nil {
+				attrs = append(attrs,
+					slog.Int("status", resp.StatusCode),
+					slog.Int64("response_size", resp.ContentLength),
+				)
+				if len(opts.Headers) > 0 {
+					attrs = append(attrs, headersAttr("response_headers", resp.Header, opts.Headers, redact))
+				}
+				if opts.BodyLimit > 0 && resp.Body != nil && !isStreaming(resp.Header) {
+					attrs = append(attrs, slog.String("response_body", string(peekResponseBody(resp, opts.BodyLimit))))
+				}
+			}
+
+			if extra, ok := LogAttrs.Value(ctx); ok {
+				attrs = append(attrs, extra...)
+			}
+
+			logger.LogAttrs(ctx, level, "http request", attrs...)
+			return resp, err
+		}
+	}
+}
+
+// streamingTypes are the media types of the responses streamed over time.
+var streamingTypes = []string{
+	"text/event-stream",
+	"application/x-ndjson",
+	"application/stream+json",
+}
+
+// isStreaming reports whether the response content type is streamed.
+func isStreaming(h http.Header) bool {
+	mt, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	return slices.Contains(streamingTypes, mt)
+}
+
+func redactURL(r *http.Request, params []string) string {
+	u := *r.URL
+	if len(params) > 0 && u.RawQuery != "" {
+		q := u.Query()
+		for _, p := range params {
+			if q.Has(p) {
+				q.Set(p, redacted)
+			}
+		}
+		u.RawQuery = q.Encode()
+	}
+	return u.Redacted()
+}
+
+func headersAttr(key string, h http.Header, allow, redact []string) slog.Attr {
+	attrs := make([]any, 0, len(allow))
+	for _, k := range allow {
+		k = http.CanonicalHeaderKey(k)
+		values := h.Values(k)
+		if len(values) == 0 {
+			continue
+		}
+		if slices.Contains(redact, k) {
+			values = []string{redacted}
+		}
+		attrs = append(attrs, slog.Any(k, values))
+	}
+	return slog.Group(key, attrs...)
+}
+
+// peekRequestBody returns up to n bytes of the request body, leaving the body
+// intact for the transport.
+func peekRequestBody(r *http.Request, n int64) []byte {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+
+	if r.GetBody != nil {
+		body, err := r.GetBody()
+		if err != nil {
+			return nil
+		}
+		defer body.Close()
+
+		b, _ := io.ReadAll(io.LimitReader(body, n))
+		return b
+	}
+
+	b, _ := io.ReadAll(io.LimitReader(r.Body, n))
+	r.Body = peekedBody{
+		Reader: io.MultiReader(bytes.NewReader(b), r.Body),
+		Closer: r.Body,
+	}
+	return b
+}
+
+// peekResponseBody returns up to n bytes of the response body, leaving the
+// body intact for the caller.
+func peekResponseBody(resp *http.Response, n int64) []byte {
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, n))
+	resp.Body = peekedBody{
+		Reader: io.MultiReader(bytes.NewReader(b), resp.Body),
+		Closer: resp.Body,
+	}
+	return b
+}
+
+// peekedBody is a body with the peeked bytes put back in front.
+type peekedBody struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,115 @@
+package httpc
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Matcher reports whether a request matches a condition.
+type Matcher func(r *http.Request) bool
+
+// When is a middleware that applies the middlewares only to the requests
+// matched by the matcher. Other requests skip them.
+//
+// The middlewares are applied once when the chain is built, as with
+// [NewClient]. Used in a [RoundTripper], the matcher is evaluated for each
+// round trip, e.g. so that credentials for an internal host are not sent
+// along a redirect to a third party.
+func When(match Matcher, mws ...MiddlewareFunc) MiddlewareFunc {
+	return func(next DoerFunc) DoerFunc {
+		matched := applyMiddlewares(next, mws...)
+		return func(r *http.Request) (*http.Response, error) {
+			if match(r) {
+				return matched(r)
+			}
+			return next(r)
+		}
+	}
+}
+
+// Unless is a middleware that applies the middlewares only to the requests
+// not matched by the matcher, see [When].
+func Unless(match Matcher, mws ...MiddlewareFunc) MiddlewareFunc {
+	return When(Not(match), mws...)
+}
+
+// MatchHost matches the requests to any of the hosts, case-insensitively.
+//
+// A host without a port matches any port. A host starting with "*." matches
+// the subdomains of the rest, e.g. "*.example.com" matches "api.example.com"
+// but not "example.com".
+func MatchHost(hosts ...string) Matcher {
+	return func(r *http.Request) bool {
+		return slices.ContainsFunc(hosts, func(h string) bool {
+			host := r.URL.Host
+			if !strings.Contains(h, ":") {
+				host = r.URL.Hostname()
+			}
+
+			if suffix, ok := strings.CutPrefix(h, "*"); ok {
+				return len(host) > len(suffix) &&
+					strings.EqualFold(host[len(host)-len(suffix):], suffix)
+			}
+			return strings.EqualFold(host, h)
+		})
+	}
+}
+
+// MatchPathPrefix matches the requests with the URL path starting with the
+// prefix.
+func MatchPathPrefix(prefix string) Matcher {
+	return func(r *http.Request) bool {
+		return strings.HasPrefix(r.URL.Path, prefix)
+	}
+}
+
+// MatchMethod matches the requests with any of the methods.
+func MatchMethod(methods ...string) Matcher {
+	return func(r *http.Request) bool {
+		return slices.Contains(methods, r.Method)
+	}
+}
+
+// MatchHeader matches the requests with the header value. An empty value
+// matches the requests with the header present.
+func MatchHeader(key, value string) Matcher {
+	return func(r *http.Request) bool {
+		values := r.Header.Values(key)
+		if value == "" {
+			return len(values) > 0
+		}
+		return slices.Contains(values, value)
+	}
+}
+
+// And matches the requests matched by all the matchers.
+func And(matchers ...Matcher) Matcher {
+	return func(r *http.Request) bool {
+		for _, m := range matchers {
+			if !m(r) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or matches the requests matched by any of the matchers.
+func Or(matchers ...Matcher) Matcher {
+	return func(r *http.Request) bool {
+		for _, m := range matchers {
+			if m(r) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not matches the requests not matched by the matcher.
+func Not(match Matcher) Matcher {
+	return func(r *http.Request) bool {
+		return !match(r)
+	}
+}
@@ -0,0 +1,145 @@
+package httpc_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/kraciasty/httpc"
+)
+
+func ExampleWhen() {
+	c := httpc.NewClient(stubDoer, httpc.When(
+		httpc.MatchHost("api.internal"),
+		httpc.AuthorizationBearer("secret"),
+	))
+
+	for _, url := range []string{"http://api.internal/users", "http://example.com"} {
+		r, _ := http.NewRequest(http.MethodGet, url, http.NoBody)
+		resp, _ := c.Do(r)
+		resp.Body.Close()
+		fmt.Printf("%s %q\n", r.URL.Host, resp.Request.Header.Get("Authorization"))
+	}
+	// Output:
+	// api.internal "Bearer secret"
+	// example.com ""
+}
+
+func TestWhen(t *testing.T) {
+	var built int
+	mw := func(next httpc.DoerFunc) httpc.DoerFunc {
+		built++
+		return func(r *http.Request) (*http.Response, error) {
+			r.Header.Set("X-Matched", "true")
+			return next(r)
+		}
+	}
+
+	c := httpc.NewClient(stubDoer,
+		httpc.When(httpc.MatchMethod(http.MethodPost), mw),
+		httpc.Unless(httpc.MatchMethod(http.MethodPost), httpc.SetHeader("X-Unmatched", "true")),
+	)
+
+	for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPost} {
+		r, err := http.NewRequest(method, "http://localhost", http.NoBody)
+		if err != nil {
+			t.Fatalf("Cannot create request: %v", err)
+		}
+		resp, err := c.Do(r)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		resp.Body.Close()
+
+		post := method == http.MethodPost
+		if got := r.Header.Get("X-Matched") == "true"; got != post {
+			t.Errorf("%s: expected matched %v but got %v", method, post, got)
+		}
+		if got := r.Header.Get("X-Unmatched") == "true"; got == post {
+			t.Errorf("%s: expected unmatched %v but got %v", method, !post, got)
+		}
+	}
+
+	if built != 1 {
+		t.Errorf("Expected the sub-chain to be built once but got %d", built)
+	}
+}
+
+func TestMatchers(t *testing.T) {
+	tests := []struct {
+		name   string
+		match  httpc.Matcher
+		method string
+		url    string
+		header http.Header
+		want   bool
+	}{
+		{name: "host", match: httpc.MatchHost("api.local"), url: "http://api.local/x", want: true},
+		{name: "host case-insensitive", match: httpc.MatchHost("API.local"), url: "http://api.LOCAL", want: true},
+		{name: "host any port", match: httpc.MatchHost("api.local"), url: "http://api.local:8080", want: true},
+		{name: "host with port", match: httpc.MatchHost("api.local:8080"), url: "http://api.local:8080", want: true},
+		{name: "host other port", match: httpc.MatchHost("api.local:8080"), url: "http://api.local:9090", want: false},
+		{name: "host mismatch", match: httpc.MatchHost("api.local"), url: "http://other.local", want: false},
+		{name: "host any", match: httpc.MatchHost("a.local", "b.local"), url: "http://b.local", want: true},
+		{name: "host wildcard", match: httpc.MatchHost("*.example.com"), url: "http://api.example.com", want: true},
+		{name: "host wildcard apex", match: httpc.MatchHost("*.example.com"), url: "http://example.com", want: false},
+		{name: "host wildcard suffix", match: httpc.MatchHost("*.example.com"), url: "http://evilexample.com", want: false},
+		{name: "path prefix", match: httpc.MatchPathPrefix("/api/"), url: "http://localhost/api/users", want: true},
+		{name: "path prefix mismatch", match: httpc.MatchPathPrefix("/api/"), url: "http://localhost/web", want: false},
+		{name: "method", match: httpc.MatchMethod(http.MethodPut, http.MethodPost), method: http.MethodPost, want: true},
+		{name: "method mismatch", match: httpc.MatchMethod(http.MethodPost), method: http.MethodGet, want: false},
+		{name: "header value", match: httpc.MatchHeader("X-Tenant", "a"), header: http.Header{"X-Tenant": {"b", "a"}}, want: true},
+		{name: "header value mismatch", match: httpc.MatchHeader("X-Tenant", "a"), header: http.Header{"X-Tenant": {"b"}}, want: false},
+		{name: "header present", match: httpc.MatchHeader("x-tenant", ""), header: http.Header{"X-Tenant": {"b"}}, want: true},
+		{name: "header absent", match: httpc.MatchHeader("X-Tenant", ""), want: false},
+		{
+			name:   "and",
+			match:  httpc.And(httpc.MatchHost("api.local"), httpc.MatchMethod(http.MethodPost)),
+			method: http.MethodPost,
+			url:    "http://api.local",
+			want:   true,
+		},
+		{
+			name:  "and mismatch",
+			match: httpc.And(httpc.MatchHost("api.local"), httpc.MatchMethod(http.MethodPost)),
+			url:   "http://api.local",
+			want:  false,
+		},
+		{
+			name:  "or",
+			match: httpc.Or(httpc.MatchHost("api.local"), httpc.MatchMethod(http.MethodPost)),
+			url:   "http://api.local",
+			want:  true,
+		},
+		{
+			name:  "or mismatch",
+			match: httpc.Or(httpc.MatchHost("api.local"), httpc.MatchMethod(http.MethodPost)),
+			want:  false,
+		},
+		{name: "not", match: httpc.Not(httpc.MatchHost("api.local")), want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method, url := tt.method, tt.url
+			if method == "" {
+				method = http.MethodGet
+			}
+			if url == "" {
+				url = "http://localhost"
+			}
+
+			r, err := http.NewRequest(method, url, http.NoBody)
+			if err != nil {
+				t.Fatalf("Cannot create request: %v", err)
+			}
+			for k, v := range tt.header {
+				r.Header[k] = v
+			}
+
+			if got := tt.match(r); got != tt.want {
+				t.Errorf("Expected match %v but got %v", tt.want, got)
+			}
+		})
+	}
+}
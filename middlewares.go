@@ -92,11 +92,21 @@ func Secure() MiddlewareFunc {
 
 // Timeout adds a timeout to the client requests.
 //
-// The middleware is not applied if the timeout is below or equal to zero.
+// The timeout can be overridden for a single request with the
+// [RequestTimeout] option. The middleware is not applied if the timeout is
+// below or equal to zero.
 // The context is only canceled after the response body is fully read/closed.
 func Timeout(timeout time.Duration) MiddlewareFunc {
 	return func(next DoerFunc) DoerFunc {
 		return func(req *http.Request) (*http.Response, error) {
+			timeout := timeout
+			if d, ok := RequestTimeout.Value(req.Context()); ok {
+				timeout = d
+			}
+			if timeout <= 0 {
+				return next(req)
+			}
+
 			ctx, cancel := context.WithTimeout(req.Context(), timeout)
 			req = req.WithContext(ctx)
 
@@ -193,6 +203,14 @@ func SetHeader(k, v string) MiddlewareFunc {
 	}
 }
 
+// HostKey returns the request URL host.
+//
+// It can be used as the key function of middlewares that keep per-host state,
+// such as [RateLimit] or [CircuitBreaker].
+func HostKey(r *http.Request) string {
+	return r.URL.Host
+}
+
 type timeoutBody struct {
 	io.ReadCloser
 	cancel context.CancelFunc
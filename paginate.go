@@ -0,0 +1,349 @@
+package httpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/kraciasty/httpc/internal/httpbody"
+)
+
+// NextPageFunc returns the request for the page following the response or
+// nil when it is the last page.
+type NextPageFunc func(resp *http.Response) (*http.Request, error)
+
+// PaginateOptions configures the [Paginate] and [PaginateJSON] iterators.
+type PaginateOptions struct {
+	// Next returns the request for the next page. Defaults to [NextLink].
+	Next NextPageFunc
+
+	// MaxPages is the maximum number of pages to request.
+	// There is no limit when zero.
+	MaxPages int
+
+	// ItemsField is the name of the field with the items of an object page
+	// decoded by [PaginateJSON]. Pages are arrays of items when empty.
+	ItemsField string
+}
+
+func (o PaginateOptions) withDefaults() PaginateOptions {
+	if o.Next == nil {
+		o.Next = NextLink
+	}
+	return o
+}
+
+// Paginate returns an iterator over the pages of a paginated resource,
+// starting with the request and following the requests returned by the
+// [PaginateOptions] Next function.
+//
+// The iteration stops after the last page, when the maximum number of pages
+// is reached or with an error. Responses with a status code other than 2xx
+// yield a [StatusError]. The request context cancellation stops the iteration
+// with the context error.
+//
+// The next pages are requested with copies of the request, so the headers set
+// by the middlewares are set again for each page. The Authorization,
+// Proxy-Authorization and Cookie headers are not sent to other hosts.
+//
+// Each response body is drained and closed once the loop body for it returns,
+// so the responses must not be used after that.
+func Paginate(d Doer, req *http.Request, opts PaginateOptions) iter.Seq2[*http.Response, error] {
+	opts = opts.withDefaults()
+
+	return func(yield func(*http.Response, error) bool) {
+		r := req.WithContext(context.WithValue(req.Context(), pageRequestKey{}, req))
+		for page := 0; r != nil; page++ {
+			if opts.MaxPages > 0 && page >= opts.MaxPages {
+				return
+			}
+			if err := r.Context().Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+
+			// The middlewares may change the request, which is kept intact
+			// for the next page.
+			resp, err := d.Do(r.Clone(r.Context()))
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if resp.Request == nil {
+				resp.Request = r
+			}
+			if resp.StatusCode < 200 || resp.StatusCode > 299 {
+				yield(nil, newStatusError(resp))
+				return
+			}
+
+			next, err := opts.Next(resp)
+			if err != nil {
+				httpbody.Drain(resp)
+				yield(nil, fmt.Errorf("next page: %w", err))
+				return
+			}
+
+			ok := yield(resp, nil)
+			httpbody.Drain(resp)
+			if !ok {
+				return
+			}
+			r = next
+		}
+	}
+}
+
+// PaginateJSON returns an iterator over the items of a paginated JSON
+// resource, see [Paginate].
+//
+// Each page is decoded as a JSON array of items, or as an object with the
+// items under the [PaginateOptions] ItemsField.
+func PaginateJSON[T any](ctx context.Context, d Doer, url string, opts PaginateOptions, jsonOpts ...JSONOption) iter.Seq2[T, error] {
+	o := newJSONOptions(jsonOpts)
+
+	return func(yield func(T, error) bool) {
+		var zero T
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+		if err != nil {
+			yield(zero, fmt.Errorf("new request: %w", err))
+			return
+		}
+		req.Header.Set("Accept", "application/json")
+
+		for resp, err := range Paginate(d, req, opts) {
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+
+			items, err := decodeItems[T](resp, opts.ItemsField, o)
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func decodeItems[T any](resp *http.Response, field string, opts jsonOptions) ([]T, error) {
+	raw, err := decodeJSON[json.RawMessage](resp, opts)
+	if err != nil || len(raw) == 0 {
+		return nil, err
+	}
+
+	if field != "" {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return nil, fmt.Errorf("decode json: %w", err)
+		}
+		raw = fields[field]
+		if raw == nil {
+			return nil, nil
+		}
+	}
+
+	var items []T
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if opts.disallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(&items); err != nil {
+		return nil, fmt.Errorf("decode json: %w", err)
+	}
+	return items, nil
+}
+
+// NextLink is a [NextPageFunc] following the RFC 8288 Link header with the
+// "next" relation type.
+func NextLink(resp *http.Response) (*http.Request, error) {
+	for _, l := range parseLinks(resp.Header.Values("Link")) {
+		if !l.hasRel("next") {
+			continue
+		}
+
+		u, err := resp.Request.URL.Parse(l.target)
+		if err != nil {
+			return nil, fmt.Errorf("parse link: %w", err)
+		}
+		return nextPageRequest(resp, u)
+	}
+	return nil, nil
+}
+
+// NextCursor returns a [NextPageFunc] that sets the query parameter to the
+// cursor extracted from the page body. An empty cursor ends the pagination.
+//
+// The body is read in full and can still be read by the caller.
+func NextCursor(param string, cursor func(body []byte) (string, error)) NextPageFunc {
+	return func(resp *http.Response) (*http.Request, error) {
+		body, err := bufferBody(resp)
+		if err != nil {
+			return nil, err
+		}
+
+		c, err := cursor(body)
+		if err != nil || c == "" {
+			return nil, err
+		}
+
+		u := *resp.Request.URL
+		q := u.Query()
+		q.Set(param, c)
+		u.RawQuery = q.Encode()
+		return nextPageRequest(resp, &u)
+	}
+}
+
+// NextOffset returns a [NextPageFunc] that increases the offset in the query
+// parameter by the number of items counted in the page body. A page without
+// items ends the pagination.
+//
+// The body is read in full and can still be read by the caller.
+func NextOffset(param string, count func(body []byte) (int, error)) NextPageFunc {
+	return func(resp *http.Response) (*http.Request, error) {
+		body, err := bufferBody(resp)
+		if err != nil {
+			return nil, err
+		}
+
+		n, err := count(body)
+		if err != nil || n <= 0 {
+			return nil, err
+		}
+
+		u := *resp.Request.URL
+		q := u.Query()
+		offset, _ := strconv.Atoi(q.Get(param))
+		q.Set(param, strconv.Itoa(offset+n))
+		u.RawQuery = q.Encode()
+		return nextPageRequest(resp, &u)
+	}
+}
+
+// pageRequestKey is the context key of the request passed to [Paginate].
+type pageRequestKey struct{}
+
+// nextPageRequest returns a copy of the request passed to [Paginate] with the
+// URL, without the headers set by the middlewares for the previous page. The
+// credentials are not sent when the URL is on another host.
+func nextPageRequest(resp *http.Response, u *url.URL) (*http.Request, error) {
+	orig, ok := resp.Request.Context().Value(pageRequestKey{}).(*http.Request)
+	if !ok {
+		orig = resp.Request
+	}
+
+	req := orig.Clone(context.WithValue(orig.Context(), pageRequestKey{}, orig))
+	req.URL = u
+	req.Host = ""
+	if !strings.EqualFold(u.Host, orig.URL.Host) {
+		for _, h := range []string{"Authorization", "Proxy-Authorization", "Cookie"} {
+			req.Header.Del(h)
+		}
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = body
+	}
+	return req, nil
+}
+
+// bufferBody reads the response body up to the default JSON limit and
+// replaces it with the read bytes.
+func bufferBody(resp *http.Response) ([]byte, error) {
+	if resp.Body == nil {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(&limitedBody{
+		ReadCloser: resp.Body,
+		remaining:  defaultMaxJSONBytes,
+		err:        ErrResponseTooLarge,
+	})
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// link is a web link of the RFC 8288 Link header.
+type link struct {
+	target string
+	rel    string
+}
+
+func (l link) hasRel(rel string) bool {
+	for r := range strings.FieldsSeq(l.rel) {
+		if strings.EqualFold(r, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseLinks parses the values of the Link header, e.g.
+//
+//	<https://api.local/items?page=2>; rel="next", <https://api.local/items?page=9>; rel="last"
+func parseLinks(values []string) []link {
+	var links []link
+	for _, v := range values {
+		for {
+			start := strings.IndexByte(v, '<')
+			if start < 0 {
+				break
+			}
+			end := strings.IndexByte(v[start:], '>')
+			if end < 0 {
+				break
+			}
+
+			l := link{target: v[start+1 : start+end]}
+			v = v[start+end+1:]
+
+			// The parameters end at the next link, outside of quoted strings.
+			params, rest := splitLinkParams(v)
+			for p := range strings.SplitSeq(params, ";") {
+				name, value, _ := strings.Cut(strings.TrimSpace(p), "=")
+				if strings.EqualFold(strings.TrimSpace(name), "rel") {
+					l.rel = strings.Trim(strings.TrimSpace(value), `"`)
+				}
+			}
+
+			links = append(links, l)
+			v = rest
+		}
+	}
+	return links
+}
+
+func splitLinkParams(s string) (params, rest string) {
+	quoted := false
+	for i, c := range s {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			return s[:i], s[i+1:]
+		}
+	}
+	return s, ""
+}
@@ -0,0 +1,358 @@
+package httpc_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/kraciasty/httpc"
+)
+
+// A doer serving the pages of items with the page query parameter, linking
+// them with the Link header.
+func pagesDoer(pages ...string) httpc.DoerFunc {
+	return func(r *http.Request) (*http.Response, error) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 1 || page > len(pages) {
+			return &http.Response{StatusCode: http.StatusNotFound, Body: http.NoBody, Request: r}, nil
+		}
+
+		h := http.Header{}
+		if page < len(pages) {
+			h.Set("Link", fmt.Sprintf(`</items?page=%d>; rel="next", </items?page=%d>; rel="last"`, page+1, len(pages)))
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     h,
+			Body:       io.NopCloser(strings.NewReader(pages[page-1])),
+			Request:    r,
+		}, nil
+	}
+}
+
+func ExamplePaginateJSON() {
+	doer := pagesDoer(`[1,2]`, `[3]`)
+
+	for item, err := range httpc.PaginateJSON[int](context.Background(), doer, "http://stuff.local/items?page=1", httpc.PaginateOptions{}) {
+		if err != nil {
+			break
+		}
+		fmt.Print(item, " ")
+	}
+	// Output: 1 2 3
+}
+
+func TestPaginate(t *testing.T) {
+	tests := []struct {
+		name      string
+		doer      httpc.DoerFunc
+		opts      httpc.PaginateOptions
+		wantPages []string
+		wantErr   error
+	}{
+		{
+			name:      "link",
+			doer:      pagesDoer("a", "b", "c"),
+			wantPages: []string{"a", "b", "c"},
+		},
+		{
+			name:      "max pages",
+			doer:      pagesDoer("a", "b", "c"),
+			opts:      httpc.PaginateOptions{MaxPages: 2},
+			wantPages: []string{"a", "b"},
+		},
+		{
+			name: "status",
+			doer: pagesDoer("a"),
+			opts: httpc.PaginateOptions{Next: func(resp *http.Response) (*http.Request, error) {
+				r := resp.Request.Clone(resp.Request.Context())
+				r.URL, _ = r.URL.Parse("/items?page=2")
+				return r, nil
+			}},
+			wantPages: []string{"a"},
+			wantErr:   httpc.ErrUnexpectedStatus,
+		},
+		{
+			name: "next error",
+			doer: pagesDoer("a"),
+			opts: httpc.PaginateOptions{Next: func(*http.Response) (*http.Request, error) {
+				return nil, errors.New("boom")
+			}},
+			wantErr: errors.New("next page: boom"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "http://localhost/items?page=1", http.NoBody)
+			if err != nil {
+				t.Fatalf("Cannot create request: %v", err)
+			}
+
+			var pages []string
+			var gotErr error
+			for resp, err := range httpc.Paginate(tt.doer, req, tt.opts) {
+				if err != nil {
+					gotErr = err
+					continue
+				}
+				b, _ := io.ReadAll(resp.Body)
+				pages = append(pages, string(b))
+			}
+
+			if !slices.Equal(pages, tt.wantPages) {
+				t.Errorf("Expected pages %v but got %v", tt.wantPages, pages)
+			}
+			switch {
+			case tt.wantErr == nil && gotErr != nil:
+				t.Errorf("Unexpected error: %v", gotErr)
+			case tt.wantErr != nil && !errors.Is(gotErr, tt.wantErr) && fmt.Sprint(gotErr) != tt.wantErr.Error():
+				t.Errorf("Expected error %v but got: %v", tt.wantErr, gotErr)
+			}
+		})
+	}
+}
+
+func TestPaginate_stop(t *testing.T) {
+	var bodies []*trackedBody
+	doer := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+		resp, err := pagesDoer("a", "b", "c")(r)
+		body := &trackedBody{Reader: resp.Body}
+		bodies = append(bodies, body)
+		resp.Body = body
+		return resp, err
+	})
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost/items?page=1", http.NoBody)
+	if err != nil {
+		t.Fatalf("Cannot create request: %v", err)
+	}
+
+	for range httpc.Paginate(doer, req, httpc.PaginateOptions{}) {
+		break
+	}
+	if len(bodies) != 1 || !bodies[0].closed {
+		t.Errorf("Expected a single closed body after break")
+	}
+
+	var gotErr error
+	for _, err := range httpc.Paginate(doer, req, httpc.PaginateOptions{}) {
+		gotErr = err
+		cancel()
+	}
+	if !errors.Is(gotErr, context.Canceled) {
+		t.Errorf("Expected context.Canceled but got: %v", gotErr)
+	}
+	if len(bodies) != 2 {
+		t.Errorf("Expected no request after cancellation but got %d", len(bodies)-1)
+	}
+}
+
+func TestNextCursor(t *testing.T) {
+	doer := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+		next := map[string]string{"": "b", "b": "c", "c": ""}[r.URL.Query().Get("cursor")]
+		body := fmt.Sprintf(`{"items":[%q],"next_cursor":%q}`, r.URL.Query().Get("cursor"), next)
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Request: r}, nil
+	})
+
+	opts := httpc.PaginateOptions{
+		Next: httpc.NextCursor("cursor", func(body []byte) (string, error) {
+			var page struct {
+				NextCursor string `json:"next_cursor"`
+			}
+			err := json.Unmarshal(body, &page)
+			return page.NextCursor, err
+		}),
+		ItemsField: "items",
+	}
+
+	var items []string
+	for item, err := range httpc.PaginateJSON[string](t.Context(), doer, "http://localhost/items?limit=1", opts) {
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		items = append(items, item)
+	}
+
+	if want := []string{"", "b", "c"}; !slices.Equal(items, want) {
+		t.Errorf("Expected items %q but got %q", want, items)
+	}
+}
+
+func TestNextOffset(t *testing.T) {
+	all := []int{1, 2, 3, 4, 5}
+	doer := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		page := all[min(offset, len(all)):min(offset+2, len(all))]
+		body, _ := json.Marshal(page)
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(string(body))), Request: r}, nil
+	})
+
+	opts := httpc.PaginateOptions{
+		Next: httpc.NextOffset("offset", func(body []byte) (int, error) {
+			var page []int
+			err := json.Unmarshal(body, &page)
+			return len(page), err
+		}),
+	}
+
+	var items []int
+	for item, err := range httpc.PaginateJSON[int](t.Context(), doer, "http://localhost/items", opts) {
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		items = append(items, item)
+	}
+
+	if !slices.Equal(items, all) {
+		t.Errorf("Expected items %v but got %v", all, items)
+	}
+}
+
+func TestNextLink(t *testing.T) {
+	tests := []struct {
+		name string
+		link []string
+		want string
+	}{
+		{name: "none"},
+		{name: "next", link: []string{`<https://api.local/items?page=2>; rel="next"`}, want: "https://api.local/items?page=2"},
+		{name: "relative", link: []string{`</items?page=2>; rel=next`}, want: "http://localhost/items?page=2"},
+		{
+			name: "many",
+			link: []string{`<https://api.local/?page=1>; rel="prev first", <https://api.local/?a=1,2>; title="a, b"; REL="Next"`},
+			want: "https://api.local/?a=1,2",
+		},
+		{
+			name: "many headers",
+			link: []string{`<https://api.local/?page=1>; rel="prev"`, `<https://api.local/?page=3>; rel="next"`},
+			want: "https://api.local/?page=3",
+		},
+		{name: "other rel", link: []string{`<https://api.local/?page=9>; rel="last"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "http://localhost/items", http.NoBody)
+			if err != nil {
+				t.Fatalf("Cannot create request: %v", err)
+			}
+			resp := &http.Response{Header: http.Header{"Link": tt.link}, Request: req}
+
+			next, err := httpc.NextLink(resp)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var got string
+			if next != nil {
+				got = next.URL.String()
+			}
+			if got != tt.want {
+				t.Errorf("Expected next page %q but got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPaginate_middlewares(t *testing.T) {
+	// The pages are gzip encoded when requested by the decompression
+	// middleware and the request IDs are recorded.
+	var ids []string
+	doer := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+		ids = append(ids, r.Header.Get(httpc.DefaultRequestIDHeader))
+		resp, err := pagesDoer("a", "b", "c")(r)
+		if err == nil && strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			b, _ := io.ReadAll(resp.Body)
+			resp.Header.Set("Content-Encoding", "gzip")
+			resp.Body = io.NopCloser(bytes.NewReader(encode(t, "gzip", b)))
+		}
+		return resp, err
+	})
+
+	var n int
+	c := httpc.NewClient(doer,
+		httpc.Decompress(httpc.DecompressOptions{}),
+		httpc.RequestID("", func() string { n++; return strconv.Itoa(n) }),
+	)
+
+	req, err := http.NewRequest(http.MethodGet, "http://localhost/items?page=1", http.NoBody)
+	if err != nil {
+		t.Fatalf("Cannot create request: %v", err)
+	}
+
+	var pages []string
+	for resp, err := range httpc.Paginate(c, req, httpc.PaginateOptions{}) {
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		pages = append(pages, string(b))
+	}
+
+	if want := []string{"a", "b", "c"}; !slices.Equal(pages, want) {
+		t.Errorf("Expected pages %v but got %v", want, pages)
+	}
+	if want := []string{"1", "2", "3"}; !slices.Equal(ids, want) {
+		t.Errorf("Expected request IDs %v but got %v", want, ids)
+	}
+}
+
+func TestPaginate_otherHost(t *testing.T) {
+	var got []http.Header
+	doer := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+		got = append(got, r.Header.Clone())
+
+		h := http.Header{}
+		switch r.URL.Host {
+		case "api.local":
+			h.Set("Link", `<http://cdn.local/items?page=2>; rel="next"`)
+		case "cdn.local":
+			h.Set("Link", `<http://api.local/items?page=3>; rel="next"`)
+		}
+		if r.URL.Query().Get("page") == "3" {
+			h.Del("Link")
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: h, Body: http.NoBody, Request: r}, nil
+	})
+
+	req, err := http.NewRequest(http.MethodGet, "http://api.local/items?page=1", http.NoBody)
+	if err != nil {
+		t.Fatalf("Cannot create request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Cookie", "session=secret")
+	req.Header.Set("Accept", "application/json")
+
+	for _, err := range httpc.Paginate(doer, req, httpc.PaginateOptions{}) {
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	if len(got) != 3 {
+		t.Fatalf("Expected 3 requests but got %d", len(got))
+	}
+	for i, wantAuth := range []string{"Bearer secret", "", "Bearer secret"} {
+		if v := got[i].Get("Authorization"); v != wantAuth {
+			t.Errorf("Request %d: expected Authorization %q but got %q", i, wantAuth, v)
+		}
+		if v := got[i].Get("Cookie"); (v != "") != (wantAuth != "") {
+			t.Errorf("Request %d: unexpected Cookie %q", i, v)
+		}
+		if v := got[i].Get("Accept"); v != "application/json" {
+			t.Errorf("Request %d: expected Accept %q but got %q", i, "application/json", v)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package httpc
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BucketState is a snapshot of a [RateLimiter] token bucket.
+type BucketState struct {
+	// Tokens is the number of available tokens.
+	// It is negative when requests are waiting for tokens.
+	Tokens float64
+
+	// Rate is the number of tokens added per second.
+	Rate float64
+
+	// Burst is the maximum number of tokens.
+	Burst int
+
+	// BlockedUntil is set when the server reported an exhausted limit and
+	// holds the time at which the limit resets.
+	BlockedUntil time.Time
+}
+
+// RateLimiter limits the rate of client requests with token buckets.
+//
+// Each request takes a token from the bucket of its key and waits until one
+// is available or the request context is done. The buckets adapt to the rate
+// limit response headers reported by the server, see [RateLimit].
+//
+// The buckets are created on demand and are never removed, so the key function
+// should return a bounded set of keys.
+type RateLimiter struct {
+	rate  float64
+	burst int
+	key   func(*http.Request) string
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// NewRateLimiter returns a new [RateLimiter] that allows rate requests per
+// second with bursts of up to burst requests for each key.
+//
+// A nil key function uses a single bucket for all requests. Use [HostKey] for
+// per-host buckets, or a custom function for per-credential buckets.
+// A rate below or equal to zero only limits the requests according to the
+// rate limit response headers.
+func NewRateLimiter(rate float64, burst int, key func(*http.Request) string) *RateLimiter {
+	if key == nil {
+		key = func(*http.Request) string { return "" }
+	}
+
+	return &RateLimiter{
+		rate:    rate,
+		burst:   max(burst, 1),
+		key:     key,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// Middleware implements [MiddlewareFunc].
+func (l *RateLimiter) Middleware(next DoerFunc) DoerFunc {
+	return func(r *http.Request) (*http.Response, error) {
+		b := l.bucket(l.key(r))
+		if err := b.wait(r); err != nil {
+			return nil, err
+		}
+
+		resp, err := next(r)
+		if err == nil {
+			b.adapt(resp, time.Now())
+		}
+
+		return resp, err
+	}
+}
+
+// State returns the state of the bucket for the given key.
+// It reports false if no request was made with the key yet.
+func (l *RateLimiter) State(key string) (BucketState, bool) {
+	l.mu.Lock()
+	b, ok := l.buckets[key]
+	l.mu.Unlock()
+	if !ok {
+		return BucketState{}, false
+	}
+
+	return b.state(time.Now()), true
+}
+
+// Buckets returns the states of all buckets by their key.
+func (l *RateLimiter) Buckets() map[string]BucketState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	states := make(map[string]BucketState, len(l.buckets))
+	for k, b := range l.buckets {
+		states[k] = b.state(now)
+	}
+	return states
+}
+
+func (l *RateLimiter) bucket(key string) *tokenBucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{
+			rate:   l.rate,
+			burst:  float64(l.burst),
+			tokens: float64(l.burst),
+			last:   time.Now(),
+		}
+		l.buckets[key] = b
+	}
+	return b
+}
+
+// RateLimit is a middleware that limits the client requests to rate requests
+// per second, with bursts of up to burst requests, for each key.
+//
+// Requests wait until a token is available or the request context is done.
+// The limits adapt to the X-RateLimit-Remaining and X-RateLimit-Reset headers,
+// the IETF draft RateLimit-Remaining, RateLimit-Reset and RateLimit headers,
+// and the Retry-After header of 429 responses.
+//
+// Use [NewRateLimiter] to inspect the state of the buckets.
+func RateLimit(rate float64, burst int, key func(*http.Request) string) MiddlewareFunc {
+	return NewRateLimiter(rate, burst, key).Middleware
+}
+
+// tokenBucket is a token bucket that lets the tokens go negative to queue the
+// waiting requests in order.
+type tokenBucket struct {
+	mu           sync.Mutex
+	rate         float64
+	burst        float64
+	tokens       float64
+	last         time.Time
+	blockedUntil time.Time
+}
+
+// refill adds the tokens accumulated since the last refill.
+// It must be called with the lock held.
+func (b *tokenBucket) refill(now time.Time) {
+	if b.rate > 0 && now.After(b.last) {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+}
+
+// reserve takes a token and returns the time to wait before using it.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var wait time.Duration
+	if now.Before(b.blockedUntil) {
+		wait = b.blockedUntil.Sub(now)
+	}
+	if b.rate <= 0 {
+		return wait
+	}
+
+	b.refill(now)
+	b.tokens--
+	if b.tokens < 0 {
+		wait = max(wait, time.Duration(-b.tokens/b.rate*float64(time.Second)))
+	}
+	return wait
+}
+
+// cancel returns a reserved token.
+func (b *tokenBucket) cancel() {
+	if b.rate <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	b.tokens = math.Min(b.burst, b.tokens+1)
+	b.mu.Unlock()
+}
+
+func (b *tokenBucket) wait(r *http.Request) error {
+	if err := sleep(r.Context(), b.reserve(time.Now())); err != nil {
+		b.cancel()
+		return err
+	}
+	return nil
+}
+
+// adapt updates the bucket with the rate limits reported by the server.
+func (b *tokenBucket) adapt(resp *http.Response, now time.Time) {
+	remaining, reset, ok := parseRateLimit(resp.Header, now)
+	if resp.StatusCode == http.StatusTooManyRequests {
+		if d, found := parseRetryAfter(resp.Header.Get("Retry-After"), now); found {
+			remaining, reset, ok = 0, d, true
+		}
+	}
+	if !ok {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(now)
+	b.tokens = math.Min(b.tokens, float64(remaining))
+	if remaining == 0 && reset > 0 {
+		b.blockedUntil = now.Add(reset)
+	}
+}
+
+func (b *tokenBucket) state(now time.Time) BucketState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(now)
+	s := BucketState{
+		Tokens: b.tokens,
+		Rate:   b.rate,
+		Burst:  int(b.burst),
+	}
+	if now.Before(b.blockedUntil) {
+		s.BlockedUntil = b.blockedUntil
+	}
+	return s
+}
+
+// parseRateLimit returns the remaining requests and the time until the limit
+// resets from the rate limit response headers.
+func parseRateLimit(h http.Header, now time.Time) (remaining int, reset time.Duration, ok bool) {
+	if v := h.Get("RateLimit"); v != "" {
+		if remaining, reset, ok = parseRateLimitField(v); ok {
+			return remaining, reset, true
+		}
+	}
+
+	for _, prefix := range []string{"RateLimit-", "X-RateLimit-"} {
+		n, err := strconv.Atoi(h.Get(prefix + "Remaining"))
+		if err != nil || n < 0 {
+			continue
+		}
+
+		secs, err := strconv.ParseInt(h.Get(prefix+"Reset"), 10, 64)
+		if err == nil && secs > 0 {
+			// Some servers report the reset as a unix timestamp instead of
+			// the number of seconds left, which are never years apart.
+			if secs > 1e8 {
+				reset = max(time.Unix(secs, 0).Sub(now), 0)
+			} else {
+				reset = time.Duration(secs) * time.Second
+			}
+		}
+		return n, reset, true
+	}
+
+	return 0, 0, false
+}
+
+// parseRateLimitField parses the structured RateLimit header field, for
+// example: "default";r=50;t=30.
+func parseRateLimitField(v string) (remaining int, reset time.Duration, ok bool) {
+	item, _, _ := strings.Cut(v, ",")
+	for param := range strings.SplitSeq(item, ";") {
+		k, val, found := strings.Cut(strings.TrimSpace(param), "=")
+		if !found {
+			continue
+		}
+
+		n, err := strconv.Atoi(val)
+		if err != nil || n < 0 {
+			continue
+		}
+
+		switch k {
+		case "r":
+			remaining, ok = n, true
+		case "t":
+			reset = time.Duration(n) * time.Second
+		}
+	}
+	return remaining, reset, ok
+}
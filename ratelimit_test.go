@@ -0,0 +1,173 @@
+package httpc_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/kraciasty/httpc"
+)
+
+func headerDoer(h http.Header) httpc.DoerFunc {
+	return func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     h.Clone(),
+			Body:       http.NoBody,
+			Request:    r,
+		}, nil
+	}
+}
+
+func doGet(t *testing.T, d httpc.Doer, ctx context.Context, url string) error {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		t.Fatalf("Cannot create request: %v", err)
+	}
+
+	resp, err := d.Do(req)
+	if err == nil {
+		resp.Body.Close()
+	}
+	return err
+}
+
+func TestRateLimit(t *testing.T) {
+	synctest.Run(func() {
+		c := httpc.NewClient(headerDoer(nil), httpc.RateLimit(2, 3, httpc.HostKey))
+		start := time.Now()
+		for range 3 {
+			if err := doGet(t, c, context.Background(), "http://a.local"); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+		if took := time.Since(start); took != 0 {
+			t.Errorf("Expected burst to pass immediately but took %v", took)
+		}
+
+		if err := doGet(t, c, context.Background(), "http://b.local"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if took := time.Since(start); took != 0 {
+			t.Errorf("Expected other key to pass immediately but took %v", took)
+		}
+
+		for range 2 {
+			if err := doGet(t, c, context.Background(), "http://a.local"); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+		if took := time.Since(start); took != time.Second {
+			t.Errorf("Expected to wait %v but took %v", time.Second, took)
+		}
+	})
+}
+
+func TestRateLimit_contextDone(t *testing.T) {
+	synctest.Run(func() {
+		l := httpc.NewRateLimiter(1, 1, nil)
+		c := httpc.NewClient(headerDoer(nil), l.Middleware)
+		if err := doGet(t, c, context.Background(), "http://localhost"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		err := doGet(t, c, ctx, "http://localhost")
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected context.DeadlineExceeded error, got: %v", err)
+		}
+
+		state, ok := l.State("")
+		if !ok {
+			t.Fatalf("Expected bucket state")
+		}
+		if want := 0.1; state.Tokens < want-1e-9 || state.Tokens > want+1e-9 {
+			t.Errorf("Expected canceled reservation to be returned, got %v tokens", state.Tokens)
+		}
+	})
+}
+
+func TestRateLimit_adaptsToHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		header func(now time.Time) http.Header
+	}{
+		{
+			name: "x-ratelimit delta seconds",
+			header: func(time.Time) http.Header {
+				return http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {"10"}}
+			},
+		},
+		{
+			name: "x-ratelimit unix timestamp",
+			header: func(now time.Time) http.Header {
+				reset := strconv.FormatInt(now.Add(10*time.Second).Unix(), 10)
+				return http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {reset}}
+			},
+		},
+		{
+			name: "ietf draft fields",
+			header: func(time.Time) http.Header {
+				return http.Header{"Ratelimit-Remaining": {"0"}, "Ratelimit-Reset": {"10"}}
+			},
+		},
+		{
+			name: "ietf structured field",
+			header: func(time.Time) http.Header {
+				return http.Header{"Ratelimit": {`"default";r=0;t=10`}}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			synctest.Run(func() {
+				start := time.Now()
+				l := httpc.NewRateLimiter(100, 10, nil)
+				c := httpc.NewClient(headerDoer(tt.header(start)), l.Middleware)
+				if err := doGet(t, c, context.Background(), "http://localhost"); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+
+				buckets := l.Buckets()
+				if got := buckets[""].BlockedUntil; !got.Equal(start.Add(10 * time.Second)) {
+					t.Errorf("Expected bucket to be blocked until %v but got %v", start.Add(10*time.Second), got)
+				}
+
+				if err := doGet(t, c, context.Background(), "http://localhost"); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if took := time.Since(start); took != 10*time.Second {
+					t.Errorf("Expected to wait %v but took %v", 10*time.Second, took)
+				}
+			})
+		})
+	}
+}
+
+func TestRateLimit_remainingCapsTokens(t *testing.T) {
+	synctest.Run(func() {
+		l := httpc.NewRateLimiter(1, 10, nil)
+		h := http.Header{"X-Ratelimit-Remaining": {"2"}}
+		c := httpc.NewClient(headerDoer(h), l.Middleware)
+		if err := doGet(t, c, context.Background(), "http://localhost"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		state, _ := l.State("")
+		if state.Tokens != 2 || state.Burst != 10 || state.Rate != 1 {
+			t.Errorf("Unexpected bucket state: %+v", state)
+		}
+
+		if _, ok := l.State("unknown"); ok {
+			t.Errorf("Expected no state for unknown key")
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package httpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// DefaultRequestIDHeader is the default header of the [RequestID] middleware.
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// requestID is the request ID option in the request context.
+var requestID = NewRequestOption[string]("request id")
+
+// WithRequestID returns a copy of the context with the request ID, e.g. of an
+// inbound server request, to propagate in the outbound requests by the
+// [RequestID] middleware.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return requestID.With(ctx, id)
+}
+
+// RequestIDFromContext returns the request ID from the context and reports
+// whether it was set.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := requestID.Value(ctx)
+	return id, ok && id != ""
+}
+
+// RequestIDError is returned by the [RequestID] middleware for the errors of
+// the chain, so that they can be correlated with the request ID.
+type RequestIDError struct {
+	ID  string // The request ID.
+	Err error  // The error of the chain.
+}
+
+// Error returns a string representation of the error with the request ID.
+func (e *RequestIDError) Error() string {
+	return fmt.Sprintf("request %s: %v", e.ID, e.Err)
+}
+
+// Unwrap returns the error of the chain.
+func (e *RequestIDError) Unwrap() error {
+	return e.Err
+}
+
+// RequestID is a middleware that sets the request ID header.
+//
+// The ID is taken from the header if already set, from the context if set
+// with [WithRequestID], or generated with gen. It is stored in the request
+// context for the next middlewares, see [RequestIDFromContext], and the
+// errors of the chain are wrapped in a [RequestIDError].
+//
+// The header defaults to [DefaultRequestIDHeader] when empty and the IDs are
+// generated with [NewUUIDv7] when gen is nil.
+func RequestID(header string, gen func() string) MiddlewareFunc {
+	if header == "" {
+		header = DefaultRequestIDHeader
+	}
+	if gen == nil {
+		gen = NewUUIDv7
+	}
+
+	return func(next DoerFunc) DoerFunc {
+		return func(r *http.Request) (*http.Response, error) {
+			id := r.Header.Get(header)
+			if id == "" {
+				var ok bool
+				if id, ok = RequestIDFromContext(r.Context()); !ok {
+					id = gen()
+				}
+				r.Header.Set(header, id)
+			}
+
+			if current, _ := RequestIDFromContext(r.Context()); current != id {
+				r = r.WithContext(WithRequestID(r.Context(), id))
+			}
+
+			resp, err := next(r)
+			if err != nil {
+				return resp, &RequestIDError{ID: id, Err: err}
+			}
+			return resp, nil
+		}
+	}
+}
+
+// NewUUIDv7 returns a new RFC 9562 version 7 UUID, which is ordered by the
+// creation time.
+func NewUUIDv7() string {
+	var b [16]byte
+	_, _ = rand.Read(b[6:])
+
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], uint64(time.Now().UnixMilli()))
+	copy(b[:6], ts[2:])
+
+	b[6] = 0x70 | b[6]&0x0f // Version 7.
+	b[8] = 0x80 | b[8]&0x3f // Variant 10.
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
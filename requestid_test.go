@@ -0,0 +1,155 @@
+package httpc_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/kraciasty/httpc"
+)
+
+func ExampleRequestID() {
+	c := httpc.NewClient(stubDoer, httpc.RequestID("", nil))
+
+	// Propagate the ID of an inbound request.
+	ctx := httpc.WithRequestID(context.Background(), "inbound-id")
+	r, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://stuff.local", http.NoBody)
+	resp, _ := c.Do(r)
+	defer resp.Body.Close()
+
+	fmt.Println(resp.Request.Header.Get("X-Request-ID"))
+	// Output: inbound-id
+}
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		ctx    context.Context
+		preset string
+		want   string
+	}{
+		{
+			name: "generated",
+			ctx:  context.Background(),
+			want: "generated",
+		},
+		{
+			name: "from context",
+			ctx:  httpc.WithRequestID(context.Background(), "inbound"),
+			want: "inbound",
+		},
+		{
+			name:   "preset header",
+			ctx:    httpc.WithRequestID(context.Background(), "inbound"),
+			preset: "preset",
+			want:   "preset",
+		},
+		{
+			name:   "custom header",
+			header: "X-Correlation-ID",
+			ctx:    context.Background(),
+			want:   "generated",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := tt.header
+			if header == "" {
+				header = "X-Request-ID"
+			}
+
+			var fromCtx string
+			doer := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+				fromCtx, _ = httpc.RequestIDFromContext(r.Context())
+				checkHeader(t, r, header, tt.want)
+				return stubDoer(r)
+			})
+			gen := func() string { return "generated" }
+			c := httpc.NewClient(doer, httpc.RequestID(tt.header, gen))
+
+			r, err := http.NewRequestWithContext(tt.ctx, http.MethodGet, "http://localhost", http.NoBody)
+			if err != nil {
+				t.Fatalf("Cannot create request: %v", err)
+			}
+			if tt.preset != "" {
+				r.Header.Set(header, tt.preset)
+			}
+
+			resp, err := c.Do(r)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if fromCtx != tt.want {
+				t.Errorf("Expected request ID %q in the context but got %q", tt.want, fromCtx)
+			}
+		})
+	}
+}
+
+func TestRequestID_error(t *testing.T) {
+	errBoom := errors.New("boom")
+	doer := httpc.DoerFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errBoom
+	})
+	c := httpc.NewClient(doer, httpc.RequestID("", func() string { return "abc" }))
+
+	err := doGet(t, c, context.Background(), "http://localhost")
+	if !errors.Is(err, errBoom) {
+		t.Errorf("Expected the chain error but got: %v", err)
+	}
+
+	var idErr *httpc.RequestIDError
+	if !errors.As(err, &idErr) || idErr.ID != "abc" {
+		t.Fatalf("Expected a request ID error with ID %q but got: %v", "abc", err)
+	}
+	if want := "request abc: boom"; err.Error() != want {
+		t.Errorf("Expected message %q but got %q", want, err)
+	}
+}
+
+func TestRequestID_log(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	c := httpc.NewClient(stubDoer,
+		httpc.RequestID("", func() string { return "abc" }),
+		httpc.Log(logger, httpc.LogOptions{}),
+	)
+
+	if err := doGet(t, c, context.Background(), "http://localhost"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	records := logRecords(t, &buf)
+	if len(records) != 1 || records[0]["request_id"] != "abc" {
+		t.Errorf("Expected a record with the request ID but got %v", records)
+	}
+}
+
+func TestNewUUIDv7(t *testing.T) {
+	re := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	prev := ""
+	seen := make(map[string]bool)
+	for range 100 {
+		id := httpc.NewUUIDv7()
+		if !re.MatchString(id) {
+			t.Fatalf("Invalid UUIDv7 %q", id)
+		}
+		if seen[id] {
+			t.Fatalf("Duplicate UUIDv7 %q", id)
+		}
+		if id[:13] < prev {
+			t.Errorf("Expected UUIDv7 %q to be ordered after %q", id, prev)
+		}
+		seen[id], prev = true, id[:13]
+	}
+}
@@ -0,0 +1,269 @@
+package httpc
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kraciasty/httpc/internal/httpbody"
+)
+
+// Backoff returns the delay to wait before the given retry.
+// The retry number starts at 1 for the first retry.
+type Backoff func(retry int) time.Duration
+
+// ExponentialBackoff returns a [Backoff] that doubles the base delay with each
+// retry, up to the max delay, and applies full jitter to the result.
+//
+// With full jitter the actual delay is a random duration between zero and the
+// computed exponential delay, which spreads retries of concurrent clients.
+func ExponentialBackoff(base, maxDelay time.Duration) Backoff {
+	return func(retry int) time.Duration {
+		d := base
+		for i := 1; i < retry && d < maxDelay; i++ {
+			d *= 2
+		}
+		if d > maxDelay {
+			d = maxDelay
+		}
+		if d <= 0 {
+			return 0
+		}
+
+		return rand.N(d + 1)
+	}
+}
+
+// RetryPolicy configures the [Retry] middleware.
+//
+// The zero value is usable and retries up to 3 attempts on transport errors
+// and the 429, 502, 503 and 504 status codes.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one.
+	// Defaults to 3 when zero or negative.
+	MaxAttempts int
+
+	// Backoff computes the delay between attempts.
+	// Defaults to ExponentialBackoff(100*time.Millisecond, 10*time.Second).
+	Backoff Backoff
+
+	// StatusCodes lists the response status codes that should be retried.
+	// Defaults to 429, 502, 503 and 504 when empty.
+	StatusCodes []int
+
+	// MaxRetryAfter caps the delay requested by the Retry-After header.
+	// Responses asking to wait longer are returned without retrying.
+	// Zero means no cap.
+	MaxRetryAfter time.Duration
+
+	// Budget optionally limits the number of concurrent retries.
+	// A nil budget allows unlimited retries.
+	Budget *RetryBudget
+}
+
+// defaultRetryStatusCodes are the status codes retried by default.
+var defaultRetryStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 3
+	}
+	if p.Backoff == nil {
+		p.Backoff = ExponentialBackoff(100*time.Millisecond, 10*time.Second)
+	}
+	if len(p.StatusCodes) == 0 {
+		p.StatusCodes = defaultRetryStatusCodes
+	}
+	return p
+}
+
+func (p RetryPolicy) shouldRetry(r *http.Request, resp *http.Response, err error) bool {
+	if r.Context().Err() != nil {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+
+	return slices.Contains(p.StatusCodes, resp.StatusCode)
+}
+
+// RetryBudget limits the number of retries in flight relative to the number
+// of requests in flight, so a degraded upstream is not flooded with retries.
+//
+// A budget is safe for concurrent use and is meant to be shared by all
+// requests of a client.
+type RetryBudget struct {
+	ratio      float64
+	minRetries int
+
+	mu       sync.Mutex
+	requests int
+	retries  int
+}
+
+// NewRetryBudget returns a [RetryBudget] that allows the retries in flight to
+// reach ratio of the requests in flight, but always allows at least the
+// minRetries concurrent retries.
+//
+// For example, a ratio of 0.2 with minRetries of 3 allows 3 concurrent
+// retries for up to 15 requests in flight and 20 for 100 requests in flight.
+func NewRetryBudget(ratio float64, minRetries int) *RetryBudget {
+	return &RetryBudget{
+		ratio:      ratio,
+		minRetries: minRetries,
+	}
+}
+
+func (b *RetryBudget) begin() {
+	b.mu.Lock()
+	b.requests++
+	b.mu.Unlock()
+}
+
+func (b *RetryBudget) end() {
+	b.mu.Lock()
+	b.requests--
+	b.mu.Unlock()
+}
+
+func (b *RetryBudget) acquire() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	limit := max(b.minRetries, int(b.ratio*float64(b.requests)))
+	if b.retries >= limit {
+		return false
+	}
+
+	b.retries++
+	return true
+}
+
+func (b *RetryBudget) release() {
+	b.mu.Lock()
+	b.retries--
+	b.mu.Unlock()
+}
+
+// Retry is a middleware that retries requests failing with a transport error
+// or a retryable status code, as configured by the [RetryPolicy].
+//
+// Request bodies are rewound with [http.Request.GetBody] before each retry.
+// Requests with a body that cannot be rewound or with the [SkipRetry] option
+// are attempted only once.
+//
+// The delay between attempts is taken from the Retry-After response header
+// when present, otherwise from the policy backoff. Retrying stops when the
+// request context is done and the bodies of discarded responses are drained
+// and closed so that the connections can be reused.
+func Retry(policy RetryPolicy) MiddlewareFunc {
+	policy = policy.withDefaults()
+	return func(next DoerFunc) DoerFunc {
+		return func(r *http.Request) (*http.Response, error) {
+			if skip, _ := SkipRetry.Value(r.Context()); skip || !httpbody.Rewindable(r) {
+				return next(r)
+			}
+
+			budget := policy.Budget
+			if budget != nil {
+				budget.begin()
+				defer budget.end()
+			}
+
+			req := r
+			retrying := false
+			for attempt := 1; ; attempt++ {
+				resp, err := next(req)
+				if retrying {
+					budget.release()
+					retrying = false
+				}
+
+				if attempt >= policy.MaxAttempts || !policy.shouldRetry(r, resp, err) {
+					return resp, err
+				}
+
+				delay := policy.Backoff(attempt)
+				if resp != nil {
+					if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+						if policy.MaxRetryAfter > 0 && d > policy.MaxRetryAfter {
+							return resp, err
+						}
+						delay = d
+					}
+				}
+
+				if budget != nil {
+					if !budget.acquire() {
+						return resp, err
+					}
+					retrying = true
+				}
+
+				httpbody.Drain(resp)
+				if err := sleep(r.Context(), delay); err != nil {
+					if retrying {
+						budget.release()
+					}
+					return nil, err
+				}
+
+				req, err = httpbody.Rewind(r)
+				if err != nil {
+					if retrying {
+						budget.release()
+					}
+					return nil, err
+				}
+			}
+		}
+	}
+}
+
+// sleep waits for the duration or until the context is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// parseRetryAfter parses the Retry-After header value, which is either
+// a number of seconds or an HTTP date.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+
+	return max(t.Sub(now), 0), true
+}
@@ -0,0 +1,340 @@
+package httpc_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/kraciasty/httpc"
+)
+
+// A stub response with the given status code and a tracked body.
+type stubAttempt struct {
+	status int
+	header http.Header
+	err    error
+}
+
+type trackedBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *trackedBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+// A doer that replies with the provided attempts in order and records
+// the received request bodies and response bodies.
+type attemptsDoer struct {
+	attempts []stubAttempt
+	bodies   []string
+	returned []*trackedBody
+}
+
+func (d *attemptsDoer) Do(r *http.Request) (*http.Response, error) {
+	var body string
+	if r.Body != nil {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	}
+	d.bodies = append(d.bodies, body)
+
+	a := d.attempts[min(len(d.bodies), len(d.attempts))-1]
+	if a.err != nil {
+		return nil, a.err
+	}
+
+	tb := &trackedBody{Reader: strings.NewReader("attempt body")}
+	d.returned = append(d.returned, tb)
+	header := a.header
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{StatusCode: a.status, Header: header, Body: tb, Request: r}, nil
+}
+
+func ExampleRetry() {
+	doer := &attemptsDoer{attempts: []stubAttempt{
+		{status: http.StatusServiceUnavailable},
+		{status: http.StatusOK},
+	}}
+	c := httpc.NewClient(doer, httpc.Retry(httpc.RetryPolicy{
+		Backoff: func(int) time.Duration { return 0 },
+	}))
+
+	r, _ := http.NewRequest(http.MethodGet, "http://localhost", http.NoBody)
+	resp, _ := c.Do(r)
+	defer resp.Body.Close()
+	fmt.Println(resp.StatusCode, len(doer.bodies))
+	// Output: 200 2
+}
+
+func TestRetry(t *testing.T) {
+	errTransport := errors.New("transport failure")
+	tests := []struct {
+		name         string
+		policy       httpc.RetryPolicy
+		attempts     []stubAttempt
+		body         func() io.Reader
+		wantStatus   int
+		wantErr      error
+		wantAttempts int
+		wantWaited   time.Duration
+	}{
+		{
+			name: "success on first attempt",
+			attempts: []stubAttempt{
+				{status: http.StatusOK},
+			},
+			wantStatus:   http.StatusOK,
+			wantAttempts: 1,
+		},
+		{
+			name: "retries retryable status",
+			attempts: []stubAttempt{
+				{status: http.StatusBadGateway},
+				{status: http.StatusServiceUnavailable},
+				{status: http.StatusOK},
+			},
+			wantStatus:   http.StatusOK,
+			wantAttempts: 3,
+		},
+		{
+			name: "does not retry other status",
+			attempts: []stubAttempt{
+				{status: http.StatusInternalServerError},
+				{status: http.StatusOK},
+			},
+			wantStatus:   http.StatusInternalServerError,
+			wantAttempts: 1,
+		},
+		{
+			name:   "custom status codes",
+			policy: httpc.RetryPolicy{StatusCodes: []int{http.StatusInternalServerError}},
+			attempts: []stubAttempt{
+				{status: http.StatusInternalServerError},
+				{status: http.StatusOK},
+			},
+			wantStatus:   http.StatusOK,
+			wantAttempts: 2,
+		},
+		{
+			name: "retries transport errors",
+			attempts: []stubAttempt{
+				{err: errTransport},
+				{status: http.StatusOK},
+			},
+			wantStatus:   http.StatusOK,
+			wantAttempts: 2,
+		},
+		{
+			name: "gives up after max attempts",
+			attempts: []stubAttempt{
+				{err: errTransport},
+			},
+			wantErr:      errTransport,
+			wantAttempts: 3,
+		},
+		{
+			name:   "respects max attempts",
+			policy: httpc.RetryPolicy{MaxAttempts: 5},
+			attempts: []stubAttempt{
+				{status: http.StatusTooManyRequests},
+			},
+			wantStatus:   http.StatusTooManyRequests,
+			wantAttempts: 5,
+		},
+		{
+			name: "rewinds the body",
+			attempts: []stubAttempt{
+				{status: http.StatusServiceUnavailable},
+				{status: http.StatusOK},
+			},
+			body:         func() io.Reader { return strings.NewReader("payload") },
+			wantStatus:   http.StatusOK,
+			wantAttempts: 2,
+		},
+		{
+			name: "does not retry non-rewindable body",
+			attempts: []stubAttempt{
+				{status: http.StatusServiceUnavailable},
+				{status: http.StatusOK},
+			},
+			body:         func() io.Reader { return io.MultiReader(strings.NewReader("payload")) },
+			wantStatus:   http.StatusServiceUnavailable,
+			wantAttempts: 1,
+		},
+		{
+			name:   "honors Retry-After seconds",
+			policy: httpc.RetryPolicy{Backoff: func(int) time.Duration { return time.Second }},
+			attempts: []stubAttempt{
+				{status: http.StatusTooManyRequests, header: http.Header{"Retry-After": {"30"}}},
+				{status: http.StatusOK},
+			},
+			wantStatus:   http.StatusOK,
+			wantAttempts: 2,
+			wantWaited:   30 * time.Second,
+		},
+		{
+			name:   "skips retry when Retry-After exceeds the cap",
+			policy: httpc.RetryPolicy{MaxRetryAfter: time.Minute},
+			attempts: []stubAttempt{
+				{status: http.StatusServiceUnavailable, header: http.Header{"Retry-After": {"3600"}}},
+				{status: http.StatusOK},
+			},
+			wantStatus:   http.StatusServiceUnavailable,
+			wantAttempts: 1,
+		},
+		{
+			name:   "uses backoff between attempts",
+			policy: httpc.RetryPolicy{Backoff: func(n int) time.Duration { return time.Duration(n) * time.Second }},
+			attempts: []stubAttempt{
+				{status: http.StatusBadGateway},
+				{status: http.StatusBadGateway},
+				{status: http.StatusOK},
+			},
+			wantStatus:   http.StatusOK,
+			wantAttempts: 3,
+			wantWaited:   3 * time.Second,
+		},
+		{
+			name:   "exhausted budget",
+			policy: httpc.RetryPolicy{Budget: httpc.NewRetryBudget(0, 0)},
+			attempts: []stubAttempt{
+				{status: http.StatusBadGateway},
+				{status: http.StatusOK},
+			},
+			wantStatus:   http.StatusBadGateway,
+			wantAttempts: 1,
+		},
+		{
+			name:   "available budget",
+			policy: httpc.RetryPolicy{Budget: httpc.NewRetryBudget(0, 1)},
+			attempts: []stubAttempt{
+				{status: http.StatusBadGateway},
+				{status: http.StatusBadGateway},
+				{status: http.StatusOK},
+			},
+			wantStatus:   http.StatusOK,
+			wantAttempts: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			synctest.Run(func() {
+				doer := &attemptsDoer{attempts: tt.attempts}
+				c := httpc.NewClient(doer, httpc.Retry(tt.policy))
+
+				var body io.Reader = http.NoBody
+				if tt.body != nil {
+					body = tt.body()
+				}
+				req, err := http.NewRequest(http.MethodPost, "http://localhost", body)
+				if err != nil {
+					t.Fatalf("Cannot create request: %v", err)
+				}
+
+				start := time.Now()
+				resp, err := c.Do(req)
+				waited := time.Since(start)
+				if tt.wantErr != nil {
+					if !errors.Is(err, tt.wantErr) {
+						t.Errorf("Expected error %v but got: %v", tt.wantErr, err)
+					}
+				} else {
+					if err != nil {
+						t.Fatalf("Unexpected error: %v", err)
+					}
+					checkStatus(t, resp, tt.wantStatus)
+				}
+
+				if got := len(doer.bodies); got != tt.wantAttempts {
+					t.Errorf("Expected %d attempts but got %d", tt.wantAttempts, got)
+				}
+
+				if tt.wantWaited > 0 && waited != tt.wantWaited {
+					t.Errorf("Expected to wait %v but waited %v", tt.wantWaited, waited)
+				}
+
+				if tt.body != nil {
+					for i, b := range doer.bodies {
+						if b != "payload" {
+							t.Errorf("Attempt %d: expected body %q but got %q", i+1, "payload", b)
+						}
+					}
+				}
+
+				for i, b := range doer.returned {
+					if i == len(doer.returned)-1 && resp != nil {
+						break
+					}
+					if !b.closed {
+						t.Errorf("Expected discarded response %d body to be closed", i+1)
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestRetry_contextCanceled(t *testing.T) {
+	synctest.Run(func() {
+		doer := &attemptsDoer{attempts: []stubAttempt{
+			{status: http.StatusServiceUnavailable},
+		}}
+		c := httpc.NewClient(doer, httpc.Retry(httpc.RetryPolicy{
+			MaxAttempts: 10,
+			Backoff:     func(int) time.Duration { return time.Minute },
+		}))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost", http.NoBody)
+		if err != nil {
+			t.Fatalf("Cannot create request: %v", err)
+		}
+
+		_, err = c.Do(req)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected context.DeadlineExceeded error, got: %v", err)
+		}
+
+		if got := len(doer.bodies); got != 2 {
+			t.Errorf("Expected 2 attempts but got %d", got)
+		}
+	})
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := httpc.ExponentialBackoff(100*time.Millisecond, time.Second)
+	tests := []struct {
+		retry int
+		max   time.Duration
+	}{
+		{retry: 1, max: 100 * time.Millisecond},
+		{retry: 2, max: 200 * time.Millisecond},
+		{retry: 3, max: 400 * time.Millisecond},
+		{retry: 4, max: 800 * time.Millisecond},
+		{retry: 5, max: time.Second},
+		{retry: 100, max: time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.retry), func(t *testing.T) {
+			for range 100 {
+				if got := backoff(tt.retry); got < 0 || got > tt.max {
+					t.Fatalf("Expected delay within [0, %v] but got %v", tt.max, got)
+				}
+			}
+		})
+	}
+}
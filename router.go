@@ -0,0 +1,82 @@
+package httpc
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// ErrNoRoute indicates that no route of a [Router] matched the request and
+// the router has no fallback.
+var ErrNoRoute = errors.New("no route")
+
+// Router dispatches the requests to different doers, e.g. to talk to several
+// upstreams with different authentication, timeouts or retry rules through a
+// single [Doer].
+//
+// The routes are tried in the order they were added and the first route with
+// a matching [Matcher] handles the request. Unmatched requests are sent to the
+// fallback doer.
+//
+// Router implements both the [Doer] and [http.RoundTripper] interfaces.
+// The methods adding routes return a new router, leaving the original one
+// unchanged.
+type Router struct {
+	fallback Doer
+	routes   []route
+}
+
+type route struct {
+	match Matcher
+	do    DoerFunc
+}
+
+// NewRouter returns a new [Router] with the fallback doer for the unmatched
+// requests. With a nil fallback, unmatched requests fail with [ErrNoRoute].
+func NewRouter(fallback Doer) *Router {
+	return &Router{fallback: fallback}
+}
+
+// Route creates a new [Router] that sends the requests matched by the matcher
+// to the doer.
+func (rt *Router) Route(match Matcher, d Doer) *Router {
+	return rt.route(match, d.Do)
+}
+
+// RouteWith creates a new [Router] that sends the requests matched by the
+// matcher to the fallback doer through the middlewares.
+//
+// The middlewares are applied once, when the route is added.
+func (rt *Router) RouteWith(match Matcher, mws ...MiddlewareFunc) *Router {
+	return rt.route(match, applyMiddlewares(rt.fallbackDo, mws...))
+}
+
+func (rt *Router) route(match Matcher, do DoerFunc) *Router {
+	return &Router{
+		fallback: rt.fallback,
+		routes:   slices.Concat(rt.routes, []route{{match: match, do: do}}),
+	}
+}
+
+// Do implements [Doer].
+func (rt *Router) Do(r *http.Request) (*http.Response, error) {
+	for _, route := range rt.routes {
+		if route.match(r) {
+			return route.do(r)
+		}
+	}
+	return rt.fallbackDo(r)
+}
+
+// RoundTrip implements [http.RoundTripper].
+func (rt *Router) RoundTrip(r *http.Request) (*http.Response, error) {
+	return rt.Do(r)
+}
+
+func (rt *Router) fallbackDo(r *http.Request) (*http.Response, error) {
+	if rt.fallback == nil {
+		return nil, fmt.Errorf("%w: %s %s", ErrNoRoute, r.Method, r.URL.Redacted())
+	}
+	return rt.fallback.Do(r)
+}
@@ -0,0 +1,84 @@
+package httpc_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/kraciasty/httpc"
+)
+
+func ExampleRouter() {
+	users := httpc.NewClient(stubDoer, httpc.AuthorizationBearer("users-token"))
+	router := httpc.NewRouter(stubDoer).
+		Route(httpc.MatchHost("users.internal"), users).
+		RouteWith(httpc.MatchHost("billing.internal"), httpc.AuthorizationBasic("billing", "secret"))
+
+	for _, url := range []string{"http://users.internal", "http://billing.internal", "http://example.com"} {
+		r, _ := http.NewRequest(http.MethodGet, url, http.NoBody)
+		resp, _ := router.Do(r)
+		resp.Body.Close()
+		fmt.Printf("%s %q\n", r.URL.Host, resp.Request.Header.Get("Authorization"))
+	}
+	// Output:
+	// users.internal "Bearer users-token"
+	// billing.internal "Basic YmlsbGluZzpzZWNyZXQ="
+	// example.com ""
+}
+
+func TestRouter(t *testing.T) {
+	doer := func(name string) httpc.DoerFunc {
+		return func(r *http.Request) (*http.Response, error) {
+			r.Header.Set("X-Route", name)
+			return stubDoer(r)
+		}
+	}
+
+	router := httpc.NewRouter(doer("fallback")).
+		Route(httpc.MatchHost("a.local"), doer("a")).
+		Route(httpc.And(httpc.MatchHost("b.local"), httpc.MatchMethod(http.MethodPost)), doer("b-post")).
+		Route(httpc.MatchPathPrefix("/b/"), doer("b-path")).
+		RouteWith(httpc.MatchHost("c.local"), httpc.SetHeader("X-Middleware", "c"))
+
+	tests := []struct {
+		method    string
+		url       string
+		wantRoute string
+		wantMw    string
+	}{
+		{method: http.MethodGet, url: "http://a.local/b/", wantRoute: "a"},
+		{method: http.MethodPost, url: "http://b.local/b/", wantRoute: "b-post"},
+		{method: http.MethodGet, url: "http://b.local/b/", wantRoute: "b-path"},
+		{method: http.MethodGet, url: "http://c.local", wantRoute: "fallback", wantMw: "c"},
+		{method: http.MethodGet, url: "http://d.local", wantRoute: "fallback"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.url, func(t *testing.T) {
+			r, err := http.NewRequest(tt.method, tt.url, http.NoBody)
+			if err != nil {
+				t.Fatalf("Cannot create request: %v", err)
+			}
+
+			resp, err := (&http.Client{Transport: router}).Do(r)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			defer resp.Body.Close()
+
+			checkHeader(t, resp.Request, "X-Route", tt.wantRoute)
+			checkHeader(t, resp.Request, "X-Middleware", tt.wantMw)
+		})
+	}
+}
+
+func TestRouter_immutable(t *testing.T) {
+	base := httpc.NewRouter(nil)
+	_ = base.Route(httpc.MatchHost("localhost"), stubDoer)
+
+	err := doGet(t, base, t.Context(), "http://localhost")
+	if !errors.Is(err, httpc.ErrNoRoute) {
+		t.Errorf("Expected httpc.ErrNoRoute but got: %v", err)
+	}
+}
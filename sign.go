@@ -0,0 +1,278 @@
+package httpc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HMACFormat is the format of the signature headers of [SignHMAC].
+type HMACFormat int
+
+const (
+	// HMACFormatHeader signs the [CanonicalRequest] and sends the signature
+	// in a single header, with the Digest header (RFC 3230) of the body.
+	HMACFormatHeader HMACFormat = iota
+
+	// HMACFormatRFC9421 sends the Signature and Signature-Input headers of
+	// the HTTP Message Signatures (RFC 9421), with the Content-Digest header
+	// (RFC 9530) of the body.
+	HMACFormatRFC9421
+)
+
+// HMACOptions configures the [SignHMAC] middleware.
+type HMACOptions struct {
+	// Format is the format of the signature. Defaults to [HMACFormatHeader].
+	Format HMACFormat
+
+	// Hash is the hash function of the HMAC and the body digest.
+	// Defaults to SHA-256.
+	Hash func() hash.Hash
+
+	// Algorithm is the name of the signature algorithm sent with the
+	// signature. The name of the body digest algorithm is derived from it,
+	// e.g. sha-256 for hmac-sha256. Defaults to "hmac-sha256".
+	Algorithm string
+
+	// Headers are the names of the signed headers. Defaults to the host,
+	// date and digest headers for [HMACFormatHeader], and to the date and
+	// content-digest headers for [HMACFormatRFC9421], which also signs the
+	// method, authority, path and query components.
+	Headers []string
+
+	// Header is the name of the header with the signature for
+	// [HMACFormatHeader]. Defaults to "Authorization".
+	Header string
+
+	// Label is the signature label for [HMACFormatRFC9421].
+	// Defaults to "sig1".
+	Label string
+
+	// Canonical builds the signed string for [HMACFormatHeader].
+	// Defaults to [CanonicalRequest].
+	Canonical func(r *http.Request, headers []string) (string, error)
+}
+
+func (o HMACOptions) withDefaults() HMACOptions {
+	if o.Hash == nil {
+		o.Hash = sha256.New
+	}
+	if o.Algorithm == "" {
+		o.Algorithm = "hmac-sha256"
+	}
+	if o.Headers == nil {
+		if o.Format == HMACFormatRFC9421 {
+			o.Headers = []string{"date", "content-digest"}
+		} else {
+			o.Headers = []string{"host", "date", "digest"}
+		}
+	}
+	if o.Header == "" {
+		o.Header = "Authorization"
+	}
+	if o.Label == "" {
+		o.Label = "sig1"
+	}
+	if o.Canonical == nil {
+		o.Canonical = CanonicalRequest
+	}
+	return o
+}
+
+// SignHMAC is a middleware that signs the requests with an HMAC of the
+// secret, identified by the key ID.
+//
+// The Date header is set unless already present, and the body digest header
+// is computed from the body read with [http.Request.GetBody], or buffered in
+// memory when it is not set. The body is sent intact and stays rewindable.
+//
+// With [HMACFormatHeader], the header is set to a value like:
+//
+//	HMAC keyId="key", algorithm="hmac-sha256", headers="host date digest", signature="base64"
+func SignHMAC(keyID string, secret []byte, opts HMACOptions) MiddlewareFunc {
+	opts = opts.withDefaults()
+
+	return func(next DoerFunc) DoerFunc {
+		return func(r *http.Request) (*http.Response, error) {
+			if err := signHMAC(r, keyID, secret, opts); err != nil {
+				return nil, fmt.Errorf("sign request: %w", err)
+			}
+			return next(r)
+		}
+	}
+}
+
+func signHMAC(r *http.Request, keyID string, secret []byte, opts HMACOptions) error {
+	body, err := digestBody(r)
+	if err != nil {
+		return err
+	}
+	sum := opts.Hash()
+	sum.Write(body)
+	digest := base64.StdEncoding.EncodeToString(sum.Sum(nil))
+	digestAlg := digestAlgorithmName(opts.Algorithm)
+
+	if r.Header.Get("Date") == "" {
+		r.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	}
+
+	mac := hmac.New(opts.Hash, secret)
+	if opts.Format == HMACFormatRFC9421 {
+		r.Header.Set("Content-Digest", digestAlg+"=:"+digest+":")
+
+		params := fmt.Sprintf(";created=%d;keyid=%s;alg=%s", time.Now().Unix(), strconv.Quote(keyID), strconv.Quote(opts.Algorithm))
+		base, input, err := signatureBase(r, opts.Headers, params)
+		if err != nil {
+			return err
+		}
+		mac.Write([]byte(base))
+
+		r.Header.Set("Signature-Input", opts.Label+"="+input)
+		r.Header.Set("Signature", opts.Label+"=:"+base64.StdEncoding.EncodeToString(mac.Sum(nil))+":")
+		return nil
+	}
+
+	r.Header.Set("Digest", strings.ToUpper(digestAlg)+"="+digest)
+
+	canonical, err := opts.Canonical(r, opts.Headers)
+	if err != nil {
+		return err
+	}
+	mac.Write([]byte(canonical))
+
+	r.Header.Set(opts.Header, fmt.Sprintf(`HMAC keyId=%s, algorithm=%s, headers=%s, signature=%s`,
+		strconv.Quote(keyID), strconv.Quote(opts.Algorithm),
+		strconv.Quote(strings.ToLower(strings.Join(opts.Headers, " "))),
+		strconv.Quote(base64.StdEncoding.EncodeToString(mac.Sum(nil)))))
+	return nil
+}
+
+// digestAlgorithmName returns the lowercase name of the digest algorithm of
+// the HMAC algorithm, e.g. sha-256 for hmac-sha256.
+func digestAlgorithmName(alg string) string {
+	name := strings.TrimPrefix(strings.ToLower(alg), "hmac-")
+	if rest, ok := strings.CutPrefix(name, "sha"); ok && !strings.HasPrefix(rest, "-") {
+		name = "sha-" + rest
+	}
+	return name
+}
+
+// CanonicalRequest returns the canonical form of the request signed by
+// [SignHMAC], with the lines:
+//
+//	METHOD
+//	/escaped/path
+//	sorted=query&params=escaped
+//	header:value
+//
+// with a line per header, in the provided order, with the lowercase name and
+// the trimmed values joined with a comma. The host header is taken from the
+// request host. It returns an error when a header is missing.
+func CanonicalRequest(r *http.Request, headers []string) (string, error) {
+	path := r.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	lines := []string{strings.ToUpper(r.Method), path, canonicalQuery(r.URL.Query())}
+	for _, name := range headers {
+		name = strings.ToLower(name)
+		value, ok := headerValue(r, name)
+		if !ok {
+			return "", fmt.Errorf("missing signed header %q", name)
+		}
+		lines = append(lines, name+":"+value)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// canonicalQuery returns the query sorted by the keys and the values.
+func canonicalQuery(q url.Values) string {
+	var params []string
+	for k, vs := range q {
+		for _, v := range vs {
+			params = append(params, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+	}
+	slices.Sort(params)
+	return strings.Join(params, "&")
+}
+
+// headerValue returns the trimmed values of the header joined with a comma,
+// and reports whether the header is present.
+func headerValue(r *http.Request, name string) (string, bool) {
+	if name == "host" {
+		return requestHost(r), true
+	}
+
+	values := slices.Clone(r.Header.Values(name))
+	if len(values) == 0 {
+		return "", false
+	}
+	for i, v := range values {
+		values[i] = strings.TrimSpace(v)
+	}
+	return strings.Join(values, ", "), true
+}
+
+// requestHost returns the lowercase host of the request without the default
+// port of the scheme.
+func requestHost(r *http.Request) string {
+	host := r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+	host = strings.ToLower(host)
+
+	if h, port, err := net.SplitHostPort(host); err == nil {
+		if port == "80" && r.URL.Scheme == "http" || port == "443" && r.URL.Scheme == "https" {
+			return h
+		}
+	}
+	return host
+}
+
+// signatureBase returns the RFC 9421 signature base of the method,
+// authority, path, query and the headers, and the signature params.
+func signatureBase(r *http.Request, headers []string, params string) (base, input string, err error) {
+	path := r.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	components := [][2]string{
+		{"@method", strings.ToUpper(r.Method)},
+		{"@authority", requestHost(r)},
+		{"@path", path},
+		{"@query", "?" + r.URL.RawQuery},
+	}
+	for _, name := range headers {
+		name = strings.ToLower(name)
+		value, ok := headerValue(r, name)
+		if !ok {
+			return "", "", fmt.Errorf("missing signed header %q", name)
+		}
+		components = append(components, [2]string{name, value})
+	}
+
+	var b strings.Builder
+	ids := make([]string, 0, len(components))
+	for _, c := range components {
+		id := strconv.Quote(c[0])
+		ids = append(ids, id)
+		fmt.Fprintf(&b, "%s: %s\n", id, c[1])
+	}
+
+	input = "(" + strings.Join(ids, " ") + ")" + params
+	fmt.Fprintf(&b, "%q: %s", "@signature-params", input)
+	return b.String(), input, nil
+}
@@ -0,0 +1,178 @@
+package httpc_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/synctest"
+
+	"github.com/kraciasty/httpc"
+)
+
+func hmacSHA256(secret, s string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(s))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func ExampleCanonicalRequest() {
+	r, _ := http.NewRequest(http.MethodGet, "https://api.local/users?sort=name&page=2", http.NoBody)
+	r.Header.Set("X-Tenant", " acme ")
+
+	s, _ := httpc.CanonicalRequest(r, []string{"Host", "X-Tenant"})
+	fmt.Println(s)
+	// Output:
+	// GET
+	// /users
+	// page=2&sort=name
+	// host:api.local
+	// x-tenant:acme
+}
+
+func TestSignHMAC(t *testing.T) {
+	const (
+		date   = "Sat, 01 Jan 2000 00:00:00 GMT"
+		digest = "LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ="
+	)
+
+	tests := []struct {
+		name        string
+		opts        httpc.HMACOptions
+		wantHeaders func() map[string]string
+	}{
+		{
+			name: "header",
+			wantHeaders: func() map[string]string {
+				canonical := "POST\n/a%20b\na=0&a=1&z=2\n" +
+					"host:example.com\n" +
+					"date:" + date + "\n" +
+					"digest:SHA-256=" + digest
+				return map[string]string{
+					"Date":   date,
+					"Digest": "SHA-256=" + digest,
+					"Authorization": `HMAC keyId="key-1", algorithm="hmac-sha256", headers="host date digest", signature="` +
+						hmacSHA256("secret", canonical) + `"`,
+				}
+			},
+		},
+		{
+			name: "custom header",
+			opts: httpc.HMACOptions{Header: "X-Signature", Headers: []string{"Content-Type"}},
+			wantHeaders: func() map[string]string {
+				canonical := "POST\n/a%20b\na=0&a=1&z=2\ncontent-type:text/plain"
+				return map[string]string{
+					"X-Signature": `HMAC keyId="key-1", algorithm="hmac-sha256", headers="content-type", signature="` +
+						hmacSHA256("secret", canonical) + `"`,
+				}
+			},
+		},
+		{
+			name: "rfc 9421",
+			opts: httpc.HMACOptions{Format: httpc.HMACFormatRFC9421},
+			wantHeaders: func() map[string]string {
+				input := `("@method" "@authority" "@path" "@query" "date" "content-digest");created=946684800;keyid="key-1";alg="hmac-sha256"`
+				base := `"@method": POST` + "\n" +
+					`"@authority": example.com` + "\n" +
+					`"@path": /a%20b` + "\n" +
+					`"@query": ?z=2&a=1&a=0` + "\n" +
+					`"date": ` + date + "\n" +
+					`"content-digest": sha-256=:` + digest + ":\n" +
+					`"@signature-params": ` + input
+				return map[string]string{
+					"Content-Digest":  "sha-256=:" + digest + ":",
+					"Signature-Input": "sig1=" + input,
+					"Signature":       "sig1=:" + hmacSHA256("secret", base) + ":",
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			synctest.Run(func() {
+				var got *http.Request
+				var body string
+				doer := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+					b, _ := io.ReadAll(r.Body)
+					got, body = r, string(b)
+					return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+				})
+				c := httpc.NewClient(doer, httpc.SignHMAC("key-1", []byte("secret"), tt.opts))
+
+				r, err := http.NewRequest(http.MethodPost, "http://Example.com:80/a%20b?z=2&a=1&a=0", strings.NewReader("hello"))
+				if err != nil {
+					t.Fatalf("Cannot create request: %v", err)
+				}
+				r.Header.Set("Content-Type", "text/plain")
+
+				resp, err := c.Do(r)
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				defer resp.Body.Close()
+
+				for k, v := range tt.wantHeaders() {
+					checkHeader(t, got, k, v)
+				}
+				if body != "hello" {
+					t.Errorf("Expected body %q but got %q", "hello", body)
+				}
+				rewound, err := got.GetBody()
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if b, _ := io.ReadAll(rewound); string(b) != "hello" {
+					t.Errorf("Expected the rewound body %q but got %q", "hello", b)
+				}
+			})
+		})
+	}
+}
+
+func TestSignHMAC_bufferedBody(t *testing.T) {
+	var got *http.Request
+	var body string
+	doer := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+		b, _ := io.ReadAll(r.Body)
+		got, body = r, string(b)
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+	c := httpc.NewClient(doer, httpc.SignHMAC("key-1", []byte("secret"), httpc.HMACOptions{}))
+
+	r, err := http.NewRequest(http.MethodPost, "http://localhost", io.NopCloser(strings.NewReader("hello")))
+	if err != nil {
+		t.Fatalf("Cannot create request: %v", err)
+	}
+	resp, err := c.Do(r)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	checkHeader(t, got, "Digest", "SHA-256=LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ=")
+	if body != "hello" {
+		t.Errorf("Expected body %q but got %q", "hello", body)
+	}
+	if got.GetBody == nil {
+		t.Errorf("Expected the buffered body to be rewindable")
+	}
+}
+
+func TestSignHMAC_missingHeader(t *testing.T) {
+	c := httpc.NewClient(stubDoer, httpc.SignHMAC("key-1", []byte("secret"), httpc.HMACOptions{
+		Headers: []string{"X-Missing"},
+	}))
+
+	r, err := http.NewRequest(http.MethodGet, "http://localhost", http.NoBody)
+	if err != nil {
+		t.Fatalf("Cannot create request: %v", err)
+	}
+	if _, err := c.Do(r); err == nil || !strings.Contains(err.Error(), `missing signed header "x-missing"`) {
+		t.Errorf("Expected a missing header error but got: %v", err)
+	}
+}
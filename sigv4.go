@@ -0,0 +1,478 @@
+package httpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/kraciasty/httpc/internal/httpbody"
+)
+
+const (
+	sigV4Algorithm      = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat     = "20060102T150405Z"
+	sigV4UnsignedBody   = "UNSIGNED-PAYLOAD"
+	sigV4StreamingBody  = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	sigV4ChunkAlgorithm = "AWS4-HMAC-SHA256-PAYLOAD"
+	sigV4ChunkSize      = 64 << 10
+	sigV4MaxExpiry      = 7 * 24 * time.Hour
+)
+
+// sigV4EmptyHash is the SHA-256 of an empty payload.
+var sigV4EmptyHash = hex.EncodeToString(sha256.New().Sum(nil))
+
+// sigV4IgnoredHeaders are not signed, as they may be changed on the way.
+var sigV4IgnoredHeaders = []string{
+	"authorization",
+	"connection",
+	"expect",
+	"transfer-encoding",
+	"user-agent",
+	"x-amzn-trace-id",
+}
+
+// AWSCredentials are the credentials of the AWS Signature Version 4.
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string // The session token of temporary credentials.
+}
+
+// AWSCredentialsProvider provides the [AWSCredentials], e.g. refreshing
+// temporary credentials before they expire.
+type AWSCredentialsProvider interface {
+	Retrieve(ctx context.Context) (AWSCredentials, error)
+}
+
+// AWSCredentialsProviderFunc is an adapter to allow the use of ordinary
+// functions as an [AWSCredentialsProvider].
+type AWSCredentialsProviderFunc func(ctx context.Context) (AWSCredentials, error)
+
+// Retrieve calls f(ctx).
+func (f AWSCredentialsProviderFunc) Retrieve(ctx context.Context) (AWSCredentials, error) {
+	return f(ctx)
+}
+
+// StaticAWSCredentials returns a provider of the credentials.
+func StaticAWSCredentials(accessKeyID, secretAccessKey, sessionToken string) AWSCredentialsProvider {
+	creds := AWSCredentials{
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secretAccessKey,
+		SessionToken:    sessionToken,
+	}
+	return AWSCredentialsProviderFunc(func(context.Context) (AWSCredentials, error) {
+		return creds, nil
+	})
+}
+
+// SigV4PayloadMode is the way the request payload is signed by [SigV4].
+type SigV4PayloadMode int
+
+const (
+	// SigV4SignedPayload signs the SHA-256 of the payload. The body is read
+	// with [http.Request.GetBody], or buffered in memory when it is not set.
+	SigV4SignedPayload SigV4PayloadMode = iota
+
+	// SigV4UnsignedPayload does not sign the payload, avoiding to read the
+	// body twice. Supported by Amazon S3 over HTTPS.
+	SigV4UnsignedPayload
+
+	// SigV4StreamingPayload sends the payload in aws-chunked encoded chunks
+	// of 64 KiB, each signed with the signature of the previous one. It
+	// requires a known request content length. Supported by Amazon S3.
+	SigV4StreamingPayload
+)
+
+// SigV4Payload is the request option of the [SigV4PayloadMode] of a request.
+var SigV4Payload = NewRequestOption[SigV4PayloadMode]("sigv4 payload")
+
+// SigV4 is a middleware that signs the requests with the AWS Signature
+// Version 4 of the credentials for the region and service, e.g. "s3".
+//
+// The payload is signed according to the [SigV4Payload] request option,
+// [SigV4SignedPayload] by default. The X-Amz-Content-Sha256 header is set
+// for the s3 service and the unsigned or streaming payloads, and the
+// X-Amz-Security-Token header for the credentials with a session token.
+// All the request headers are signed, except for the few that may change on
+// the way, so the middleware should be the last one to change the headers.
+//
+// When the server rejects a request with the RequestTimeTooSkewed error, the
+// offset of its Date header from the local clock is applied to the signing
+// time and the request is retried once. Requests with a body are retried
+// only if the body can be rewound with [http.Request.GetBody].
+func SigV4(creds AWSCredentialsProvider, region, service string) MiddlewareFunc {
+	var offset atomic.Int64 // The clock skew in nanoseconds.
+
+	return func(next DoerFunc) DoerFunc {
+		return func(r *http.Request) (*http.Response, error) {
+			mode, _ := SigV4Payload.Value(r.Context())
+
+			// Sign a copy, keeping the request to sign again on retry.
+			req := r.Clone(r.Context())
+			if err := signSigV4(req, creds, region, service, mode, time.Duration(offset.Load())); err != nil {
+				return nil, fmt.Errorf("sigv4: %w", err)
+			}
+
+			resp, err := next(req)
+			if err != nil || !httpbody.Rewindable(r) {
+				return resp, err
+			}
+
+			skew, ok := clockSkew(resp)
+			if !ok {
+				return resp, nil
+			}
+			offset.Store(int64(skew))
+
+			req, err = httpbody.Rewind(r)
+			if err != nil {
+				return resp, nil
+			}
+			httpbody.Drain(resp)
+
+			if err := signSigV4(req, creds, region, service, mode, skew); err != nil {
+				return nil, fmt.Errorf("sigv4: %w", err)
+			}
+			return next(req)
+		}
+	}
+}
+
+// clockSkew returns the offset of the server clock when the response is
+// a RequestTimeTooSkewed error.
+func clockSkew(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusBadRequest {
+		return 0, false
+	}
+
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return 0, false
+	}
+	if !bytes.Contains(peekResponseBody(resp, 4<<10), []byte("RequestTimeTooSkewed")) {
+		return 0, false
+	}
+	return time.Until(date), true
+}
+
+// PresignSigV4 returns the URL of the request presigned with the AWS Signature
+// Version 4 of the credentials for the region and service. The URL is valid
+// for the expiry duration, from a second up to 7 days, and can be used without
+// other credentials, e.g. shared to download an object.
+//
+// The host and the request headers are signed, so the requests to the URL must
+// send the same headers. The payload is not signed.
+func PresignSigV4(r *http.Request, creds AWSCredentialsProvider, region, service string, expires time.Duration) (string, error) {
+	if expires < time.Second || expires > sigV4MaxExpiry {
+		return "", fmt.Errorf("sigv4: expiry %v out of range, from 1s to %v", expires, sigV4MaxExpiry)
+	}
+
+	c, err := creds.Retrieve(r.Context())
+	if err != nil {
+		return "", fmt.Errorf("sigv4: retrieve credentials: %w", err)
+	}
+
+	now := time.Now().UTC()
+	scope := sigV4Scope(now, region, service)
+	headers := sigV4SignedHeaders(r)
+
+	u := *r.URL
+	q := u.Query()
+	q.Set("X-Amz-Algorithm", sigV4Algorithm)
+	q.Set("X-Amz-Credential", c.AccessKeyID+"/"+scope)
+	q.Set("X-Amz-Date", now.Format(sigV4TimeFormat))
+	q.Set("X-Amz-Expires", strconv.Itoa(int(expires.Seconds())))
+	q.Set("X-Amz-SignedHeaders", strings.Join(headers, ";"))
+	if c.SessionToken != "" {
+		q.Set("X-Amz-Security-Token", c.SessionToken)
+	}
+	u.RawQuery = sigV4Query(q)
+
+	presigned := r.Clone(r.Context())
+	presigned.URL = &u
+
+	creq := sigV4CanonicalRequest(presigned, service, headers, sigV4UnsignedBody)
+	sig := hex.EncodeToString(hmacSHA256(sigV4Key(c.SecretAccessKey, now, region, service), sigV4StringToSign(now, scope, creq)))
+
+	u.RawQuery += "&X-Amz-Signature=" + sig
+	return u.String(), nil
+}
+
+// signSigV4 sets the Authorization header of the request, signed at the
+// current time corrected with the clock skew.
+func signSigV4(r *http.Request, creds AWSCredentialsProvider, region, service string, mode SigV4PayloadMode, skew time.Duration) error {
+	c, err := creds.Retrieve(r.Context())
+	if err != nil {
+		return fmt.Errorf("retrieve credentials: %w", err)
+	}
+
+	now := time.Now().Add(skew).UTC()
+	scope := sigV4Scope(now, region, service)
+	key := sigV4Key(c.SecretAccessKey, now, region, service)
+
+	var payloadHash string
+	switch mode {
+	case SigV4UnsignedPayload:
+		payloadHash = sigV4UnsignedBody
+	case SigV4StreamingPayload:
+		if r.ContentLength == 0 && r.Body != nil && r.Body != http.NoBody {
+			r.ContentLength = -1
+		}
+		if r.ContentLength < 0 {
+			return errors.New("streaming payload requires the content length")
+		}
+		payloadHash = sigV4StreamingBody
+		r.Header.Set("X-Amz-Decoded-Content-Length", strconv.FormatInt(r.ContentLength, 10))
+		if enc := r.Header.Get("Content-Encoding"); enc != "" {
+			r.Header.Set("Content-Encoding", "aws-chunked,"+enc)
+		} else {
+			r.Header.Set("Content-Encoding", "aws-chunked")
+		}
+		r.ContentLength = sigV4ChunkedLength(r.ContentLength)
+	default:
+		body, err := digestBody(r)
+		if err != nil {
+			return fmt.Errorf("read body: %w", err)
+		}
+		sum := sha256.Sum256(body)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+
+	r.Header.Set("X-Amz-Date", now.Format(sigV4TimeFormat))
+	if c.SessionToken != "" {
+		r.Header.Set("X-Amz-Security-Token", c.SessionToken)
+	} else {
+		r.Header.Del("X-Amz-Security-Token")
+	}
+	if service == "s3" || mode != SigV4SignedPayload {
+		r.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	headers := sigV4SignedHeaders(r)
+	creq := sigV4CanonicalRequest(r, service, headers, payloadHash)
+	sig := hex.EncodeToString(hmacSHA256(key, sigV4StringToSign(now, scope, creq)))
+
+	r.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, c.AccessKeyID, scope, strings.Join(headers, ";"), sig))
+
+	if mode == SigV4StreamingPayload {
+		if r.Body == nil {
+			r.Body = http.NoBody
+		}
+		chunks := func(body io.ReadCloser) io.ReadCloser {
+			return struct {
+				io.Reader
+				io.Closer
+			}{&sigV4ChunkedBody{
+				r:       body,
+				key:     key,
+				prefix:  sigV4ChunkAlgorithm + "\n" + now.Format(sigV4TimeFormat) + "\n" + scope + "\n",
+				prevSig: sig,
+			}, body}
+		}
+
+		r.Body = chunks(r.Body)
+		if getBody := r.GetBody; getBody != nil {
+			r.GetBody = func() (io.ReadCloser, error) {
+				body, err := getBody()
+				if err != nil {
+					return nil, err
+				}
+				return chunks(body), nil
+			}
+		}
+	}
+	return nil
+}
+
+func sigV4Scope(t time.Time, region, service string) string {
+	return t.Format("20060102") + "/" + region + "/" + service + "/aws4_request"
+}
+
+func sigV4Key(secret string, t time.Time, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), t.Format("20060102"))
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func sigV4StringToSign(t time.Time, scope, canonicalRequest string) string {
+	sum := sha256.Sum256([]byte(canonicalRequest))
+	return sigV4Algorithm + "\n" + t.Format(sigV4TimeFormat) + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, s string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(s))
+	return mac.Sum(nil)
+}
+
+// sigV4SignedHeaders returns the sorted lowercase names of the signed headers.
+func sigV4SignedHeaders(r *http.Request) []string {
+	headers := []string{"host"}
+	if r.ContentLength > 0 {
+		headers = append(headers, "content-length")
+	}
+	for k := range r.Header {
+		k = strings.ToLower(k)
+		if !slices.Contains(sigV4IgnoredHeaders, k) && !slices.Contains(headers, k) {
+			headers = append(headers, k)
+		}
+	}
+	slices.Sort(headers)
+	return headers
+}
+
+// sigV4CanonicalRequest returns the canonical request of the AWS Signature
+// Version 4.
+func sigV4CanonicalRequest(r *http.Request, service string, headers []string, payloadHash string) string {
+	var b strings.Builder
+	b.WriteString(r.Method + "\n")
+	b.WriteString(sigV4Path(r.URL, service) + "\n")
+	b.WriteString(sigV4Query(r.URL.Query()) + "\n")
+
+	for _, k := range headers {
+		var v string
+		switch k {
+		case "host":
+			v = r.Host
+			if v == "" {
+				v = r.URL.Host
+			}
+		case "content-length":
+			v = strconv.FormatInt(r.ContentLength, 10)
+		default:
+			values := r.Header.Values(k)
+			trimmed := make([]string, len(values))
+			for i, v := range values {
+				trimmed[i] = strings.Join(strings.Fields(v), " ")
+			}
+			v = strings.Join(trimmed, ",")
+		}
+		b.WriteString(k + ":" + v + "\n")
+	}
+
+	b.WriteString("\n" + strings.Join(headers, ";") + "\n" + payloadHash)
+	return b.String()
+}
+
+// sigV4Path returns the URI-encoded path. The path segments are encoded
+// twice, except for the s3 service.
+func sigV4Path(u *url.URL, service string) string {
+	segments := strings.Split(u.EscapedPath(), "/")
+	for i, s := range segments {
+		if unescaped, err := url.PathUnescape(s); err == nil {
+			s = unescaped
+		}
+		s = sigV4Escape(s)
+		if service != "s3" {
+			s = sigV4Escape(s)
+		}
+		segments[i] = s
+	}
+
+	path := strings.Join(segments, "/")
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+// sigV4Query returns the URI-encoded query sorted by the keys and values.
+func sigV4Query(q url.Values) string {
+	var params []string
+	for k, vs := range q {
+		for _, v := range vs {
+			params = append(params, sigV4Escape(k)+"="+sigV4Escape(v))
+		}
+	}
+	slices.Sort(params)
+	return strings.Join(params, "&")
+}
+
+// sigV4Escape percent-encodes all but the unreserved characters.
+func sigV4Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// sigV4ChunkedLength returns the length of the aws-chunked encoding of
+// a payload of the length.
+func sigV4ChunkedLength(n int64) int64 {
+	chunk := func(size int64) int64 {
+		// hex(size);chunk-signature=<64 hex>\r\n<data>\r\n
+		return int64(len(strconv.FormatInt(size, 16))) + 17 + 64 + 2 + size + 2
+	}
+
+	full := n / sigV4ChunkSize
+	length := full * chunk(sigV4ChunkSize)
+	if rest := n % sigV4ChunkSize; rest > 0 {
+		length += chunk(rest)
+	}
+	return length + chunk(0)
+}
+
+// sigV4ChunkedBody encodes the body in signed aws-chunked chunks.
+type sigV4ChunkedBody struct {
+	r       io.Reader
+	key     []byte
+	prefix  string // The string to sign up to the previous signature.
+	prevSig string
+
+	buf  bytes.Buffer
+	done bool
+}
+
+func (b *sigV4ChunkedBody) Read(p []byte) (int, error) {
+	for b.buf.Len() == 0 {
+		if b.done {
+			return 0, io.EOF
+		}
+
+		chunk := make([]byte, sigV4ChunkSize)
+		n, err := io.ReadFull(b.r, chunk)
+		switch {
+		case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+			b.writeChunk(chunk[:n])
+			if n > 0 {
+				b.writeChunk(nil)
+			}
+			b.done = true
+		case err != nil:
+			return 0, err
+		default:
+			b.writeChunk(chunk)
+		}
+	}
+	return b.buf.Read(p)
+}
+
+func (b *sigV4ChunkedBody) writeChunk(data []byte) {
+	sum := sha256.Sum256(data)
+	sig := hex.EncodeToString(hmacSHA256(b.key, b.prefix+b.prevSig+"\n"+sigV4EmptyHash+"\n"+hex.EncodeToString(sum[:])))
+	b.prevSig = sig
+
+	fmt.Fprintf(&b.buf, "%x;chunk-signature=%s\r\n", len(data), sig)
+	b.buf.Write(data)
+	b.buf.WriteString("\r\n")
+}
@@ -0,0 +1,396 @@
+// Package sse provides a Server-Sent Events client on top of an [httpc.Doer].
+//
+// The event stream is parsed according to the WHATWG HTML Living Standard and
+// reconnected automatically with the Last-Event-ID header:
+//
+//	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.local/events", http.NoBody)
+//	for ev, err := range sse.Stream(client, req, sse.Options{}) {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(ev.Event, ev.Data)
+//	}
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kraciasty/httpc"
+)
+
+// ErrEventTooLarge indicates that an event exceeded the [Options] MaxEventSize.
+var ErrEventTooLarge = errors.New("event too large")
+
+// Event is a dispatched server-sent event.
+type Event struct {
+	// ID is the last event ID at the time of the dispatch.
+	ID string
+
+	// Event is the event type. Defaults to "message".
+	Event string
+
+	// Data is the event data, with the lines joined with a line feed.
+	Data string
+
+	// Retry is the reconnection delay sent by the server with the event,
+	// zero if none.
+	Retry time.Duration
+}
+
+// Options configures the [Stream].
+type Options struct {
+	// Retry is the initial reconnection delay, until the server provides one
+	// with the retry field. Defaults to 3 seconds.
+	Retry time.Duration
+
+	// MaxReconnects is the maximum number of consecutive reconnections
+	// without a dispatched event. There is no limit when zero and the stream
+	// is not reconnected when below zero.
+	MaxReconnects int
+
+	// MaxEventSize is the maximum size of an event in bytes.
+	// Defaults to 1 MiB.
+	MaxEventSize int
+
+	// LastEventID is the ID of the last event received, e.g. in a previous
+	// session. It is sent in the Last-Event-ID header of the first request.
+	LastEventID string
+}
+
+func (o Options) withDefaults() Options {
+	if o.Retry <= 0 {
+		o.Retry = 3 * time.Second
+	}
+	if o.MaxEventSize <= 0 {
+		o.MaxEventSize = 1 << 20
+	}
+	return o
+}
+
+// Stream returns an iterator over the events sent in response to the request.
+//
+// The request is sent with the Accept header set to text/event-stream.
+// When the stream ends or fails while reading, the request is sent again
+// after the reconnection delay, with the Last-Event-ID header set to the ID of
+// the last event. Requests with a body are reconnected only if the body can
+// be rewound with [http.Request.GetBody].
+//
+// The iteration stops with an error when the response has a status code other
+// than 200 OK or a content type other than text/event-stream, when the
+// reconnections are exhausted or when the request context is done.
+// A 204 No Content response stops the iteration without an error, as the
+// server's way to end the stream.
+//
+// The stream is long-lived, so it is sent with the [httpc.RequestTimeout]
+// option set to zero, which turns off the [httpc.Timeout] middleware, and
+// with the [httpc.SkipCache] option. Use the request context to bound the
+// stream lifetime.
+func Stream(d httpc.Doer, req *http.Request, opts Options) iter.Seq2[Event, error] {
+	opts = opts.withDefaults()
+
+	return func(yield func(Event, error) bool) {
+		s := &stream{
+			doer:   d,
+			req:    req,
+			opts:   opts,
+			retry:  opts.Retry,
+			lastID: opts.LastEventID,
+		}
+		s.run(yield)
+	}
+}
+
+type stream struct {
+	doer   httpc.Doer
+	req    *http.Request
+	opts   Options
+	retry  time.Duration
+	lastID string
+}
+
+func (s *stream) run(yield func(Event, error) bool) {
+	ctx := s.req.Context()
+
+	var lastErr error
+	failures := 0
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			if s.opts.MaxReconnects < 0 {
+				if !errors.Is(lastErr, io.EOF) {
+					yield(Event{}, lastErr)
+				}
+				return
+			}
+			if s.opts.MaxReconnects > 0 && failures > s.opts.MaxReconnects {
+				yield(Event{}, fmt.Errorf("sse: reconnects exhausted: %w", lastErr))
+				return
+			}
+			if err := sleep(ctx, s.retry); err != nil {
+				yield(Event{}, err)
+				return
+			}
+		}
+
+		resp, err := s.connect(attempt)
+		switch {
+		case ctx.Err() != nil:
+			yield(Event{}, ctx.Err())
+			return
+		case errors.Is(err, errFatal):
+			yield(Event{}, err)
+			return
+		case err != nil:
+			lastErr = err
+			failures++
+			continue
+		case resp == nil:
+			return
+		}
+
+		dispatched, ok, err := s.read(resp, yield)
+		_ = resp.Body.Close()
+		switch {
+		case !ok:
+			return
+		case ctx.Err() != nil:
+			yield(Event{}, ctx.Err())
+			return
+		case errors.Is(err, ErrEventTooLarge):
+			yield(Event{}, err)
+			return
+		}
+
+		if dispatched {
+			failures = 0
+		}
+		lastErr = err
+		failures++
+	}
+}
+
+// errFatal marks the errors that end the stream without reconnecting.
+var errFatal = errors.New("sse")
+
+// connect sends the request and returns the response with the event stream,
+// or nil when the server ended the stream.
+func (s *stream) connect(attempt int) (*http.Response, error) {
+	ctx := httpc.RequestTimeout.With(s.req.Context(), 0)
+	ctx = httpc.SkipCache.With(ctx, true)
+
+	req := s.req.Clone(ctx)
+	if attempt > 0 && req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, fmt.Errorf("%w: cannot reconnect with a request body without GetBody", errFatal)
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = body
+	}
+
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-cache")
+	if s.lastID != "" {
+		req.Header.Set("Last-Event-ID", s.lastID)
+	} else {
+		req.Header.Del("Last-Event-ID")
+	}
+
+	resp, err := s.doer.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNoContent {
+		_ = resp.Body.Close()
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("%w: %w", errFatal, &httpc.StatusError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Header:     resp.Header,
+		})
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/event-stream" {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("%w: unexpected content type %q", errFatal, mediaType)
+	}
+
+	return resp, nil
+}
+
+// read yields the events of the response. It reports whether any event was
+// dispatched and whether the iteration should continue.
+func (s *stream) read(resp *http.Response, yield func(Event, error) bool) (dispatched, ok bool, err error) {
+	dec := newDecoder(resp.Body, s.opts.MaxEventSize)
+	dec.idBuffer, dec.lastID = s.lastID, s.lastID
+
+	for {
+		ev, err := dec.decode()
+		s.lastID = dec.lastID
+		if dec.retry > 0 {
+			s.retry = dec.retry
+		}
+		if err != nil {
+			return dispatched, true, err
+		}
+
+		dispatched = true
+		if !yield(ev, nil) {
+			return dispatched, false, nil
+		}
+	}
+}
+
+// decoder parses an event stream.
+type decoder struct {
+	scanner *bufio.Scanner
+	maxSize int
+
+	started  bool
+	idBuffer string
+	lastID   string // The last event ID, set at the end of each event.
+	retry    time.Duration
+}
+
+func newDecoder(r io.Reader, maxSize int) *decoder {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, min(4<<10, maxSize)), maxSize)
+	sc.Split(scanLines)
+	return &decoder{scanner: sc, maxSize: maxSize}
+}
+
+// decode returns the next dispatched event. An incomplete event at the end of
+// the stream is discarded.
+func (d *decoder) decode() (Event, error) {
+	var (
+		data    strings.Builder
+		hasData bool
+		typ     string
+		retry   time.Duration
+	)
+
+	for d.scanner.Scan() {
+		line := d.scanner.Text()
+		if !d.started {
+			line = strings.TrimPrefix(line, "\ufeff")
+			d.started = true
+		}
+
+		if line == "" {
+			// The last event ID is set even when no event is dispatched.
+			d.lastID = d.idBuffer
+			if !hasData {
+				typ, retry = "", 0
+				continue
+			}
+
+			if typ == "" {
+				typ = "message"
+			}
+			return Event{
+				ID:    d.lastID,
+				Event: typ,
+				Data:  strings.TrimSuffix(data.String(), "\n"),
+				Retry: retry,
+			}, nil
+		}
+
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, found := strings.Cut(line, ":")
+		if found {
+			value = strings.TrimPrefix(value, " ")
+		}
+
+		switch field {
+		case "event":
+			typ = value
+		case "data":
+			if data.Len()+len(value) >= d.maxSize {
+				return Event{}, ErrEventTooLarge
+			}
+			data.WriteString(value)
+			data.WriteByte('\n')
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				d.idBuffer = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 63); err == nil {
+				retry = time.Duration(ms) * time.Millisecond
+				d.retry = retry
+			}
+		}
+	}
+
+	if err := d.scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return Event{}, ErrEventTooLarge
+		}
+		return Event{}, err
+	}
+	return Event{}, io.EOF
+}
+
+// scanLines is a [bufio.SplitFunc] for the lines ending with CRLF, LF or CR.
+func scanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+		if i+1 < len(data) {
+			if data[i+1] == '\n' {
+				return i + 2, data[:i], nil
+			}
+			return i + 1, data[:i], nil
+		}
+		if atEOF {
+			return i + 1, data[:i], nil
+		}
+		// Wait for the next byte, which may be the LF of a CRLF.
+		return 0, nil, nil
+	}
+
+	if atEOF {
+		// The last line without an end of line is incomplete.
+		return len(data), nil, nil
+	}
+	return 0, nil, nil
+}
+
+// sleep waits for the duration or until the context is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
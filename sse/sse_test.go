@@ -0,0 +1,307 @@
+package sse_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kraciasty/httpc"
+	"github.com/kraciasty/httpc/sse"
+)
+
+// A doer replying with the streams in order and with 204 No Content after
+// the last one. It records the Last-Event-ID headers of the requests.
+type streamsDoer struct {
+	streams []string
+	lastIDs []string
+	bodies  []*closedBody
+}
+
+type closedBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *closedBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func (d *streamsDoer) Do(r *http.Request) (*http.Response, error) {
+	d.lastIDs = append(d.lastIDs, r.Header.Get("Last-Event-ID"))
+	if len(d.lastIDs) > len(d.streams) {
+		return &http.Response{StatusCode: http.StatusNoContent, Body: http.NoBody}, nil
+	}
+
+	body := &closedBody{Reader: strings.NewReader(d.streams[len(d.lastIDs)-1])}
+	d.bodies = append(d.bodies, body)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"text/event-stream; charset=utf-8"}},
+		Body:       body,
+	}, nil
+}
+
+func collect(t *testing.T, d httpc.Doer, opts sse.Options) ([]sse.Event, error) {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, "http://localhost/events", http.NoBody)
+	if err != nil {
+		t.Fatalf("Cannot create request: %v", err)
+	}
+
+	var events []sse.Event
+	for ev, err := range sse.Stream(d, req, opts) {
+		if err != nil {
+			return events, err
+		}
+		events = append(events, ev)
+	}
+	return events, nil
+}
+
+func ExampleStream() {
+	doer := &streamsDoer{streams: []string{"event: greeting\ndata: hello\ndata: world\n\n"}}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://stuff.local/events", http.NoBody)
+	for ev, err := range sse.Stream(doer, req, sse.Options{Retry: time.Millisecond}) {
+		if err != nil {
+			break
+		}
+		fmt.Printf("%s: %q\n", ev.Event, ev.Data)
+	}
+	// Output: greeting: "hello\nworld"
+}
+
+func TestStream_parse(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		want   []sse.Event
+	}{
+		{
+			name:   "message",
+			stream: "data: hello\n\n",
+			want:   []sse.Event{{Event: "message", Data: "hello"}},
+		},
+		{
+			name:   "multi-line data",
+			stream: "data: a\ndata:b\ndata\ndata:  c\n\n",
+			want:   []sse.Event{{Event: "message", Data: "a\nb\n\n c"}},
+		},
+		{
+			name:   "line endings",
+			stream: "data: a\r\ndata: b\rdata: c\n\r\ndata: d\r\r",
+			want:   []sse.Event{{Event: "message", Data: "a\nb\nc"}, {Event: "message", Data: "d"}},
+		},
+		{
+			name:   "byte order mark and comments",
+			stream: "\ufeff: comment\ndata: a\n: another\n\n",
+			want:   []sse.Event{{Event: "message", Data: "a"}},
+		},
+		{
+			name:   "event type resets",
+			stream: "event: add\ndata: 1\n\ndata: 2\n\nevent: ignored\n\ndata: 3\n\n",
+			want: []sse.Event{
+				{Event: "add", Data: "1"},
+				{Event: "message", Data: "2"},
+				{Event: "message", Data: "3"},
+			},
+		},
+		{
+			name:   "id persists",
+			stream: "id: 1\ndata: a\n\ndata: b\n\nid\ndata: c\n\nid: x\x00\ndata: d\n\n",
+			want: []sse.Event{
+				{ID: "1", Event: "message", Data: "a"},
+				{ID: "1", Event: "message", Data: "b"},
+				{ID: "", Event: "message", Data: "c"},
+				{ID: "", Event: "message", Data: "d"},
+			},
+		},
+		{
+			name:   "retry",
+			stream: "retry: 10\ndata: a\n\nretry: x\ndata: b\n\n",
+			want: []sse.Event{
+				{Event: "message", Data: "a", Retry: 10 * time.Millisecond},
+				{Event: "message", Data: "b"},
+			},
+		},
+		{
+			name:   "unknown fields",
+			stream: "foo: bar\ndata: a\n\n",
+			want:   []sse.Event{{Event: "message", Data: "a"}},
+		},
+		{
+			name:   "incomplete event discarded",
+			stream: "data: a\n\ndata: b\n",
+			want:   []sse.Event{{Event: "message", Data: "a"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doer := &streamsDoer{streams: []string{tt.stream}}
+			events, err := collect(t, doer, sse.Options{Retry: time.Millisecond})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(events, tt.want) {
+				t.Errorf("Expected events %+v but got %+v", tt.want, events)
+			}
+		})
+	}
+}
+
+func TestStream_reconnect(t *testing.T) {
+	doer := &streamsDoer{streams: []string{
+		"retry: 1\nid: 1\ndata: a\n\nid: 2\ndata: incomplete",
+		"data: b\n\n",
+	}}
+
+	events, err := collect(t, doer, sse.Options{Retry: time.Hour, LastEventID: "0"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := []sse.Event{
+		{ID: "1", Event: "message", Data: "a", Retry: time.Millisecond},
+		{ID: "1", Event: "message", Data: "b"},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("Expected events %+v but got %+v", want, events)
+	}
+	if want := []string{"0", "1", "1"}; !reflect.DeepEqual(doer.lastIDs, want) {
+		t.Errorf("Expected Last-Event-ID headers %q but got %q", want, doer.lastIDs)
+	}
+}
+
+func TestStream_reconnectIDOnly(t *testing.T) {
+	// An event with only an ID sets the last event ID without dispatching.
+	doer := &streamsDoer{streams: []string{
+		"retry: 1\nid: 1\ndata: a\n\nid: 2\n\n",
+		"data: b\n\n",
+	}}
+
+	events, err := collect(t, doer, sse.Options{Retry: time.Hour})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := []sse.Event{
+		{ID: "1", Event: "message", Data: "a", Retry: time.Millisecond},
+		{ID: "2", Event: "message", Data: "b"},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("Expected events %+v but got %+v", want, events)
+	}
+	if want := []string{"", "2", "2"}; !reflect.DeepEqual(doer.lastIDs, want) {
+		t.Errorf("Expected Last-Event-ID headers %q but got %q", want, doer.lastIDs)
+	}
+}
+
+func TestStream_errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		doer    httpc.DoerFunc
+		opts    sse.Options
+		wantErr string
+		wantIs  error
+	}{
+		{
+			name: "status",
+			doer: func(r *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusNotFound, Status: "404 Not Found", Body: http.NoBody}, nil
+			},
+			wantErr: "sse: unexpected status: 404 Not Found",
+			wantIs:  httpc.ErrUnexpectedStatus,
+		},
+		{
+			name: "content type",
+			doer: func(r *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Content-Type": {"application/json"}},
+					Body:       http.NoBody,
+				}, nil
+			},
+			wantErr: `sse: unexpected content type "application/json"`,
+		},
+		{
+			name: "reconnects exhausted",
+			doer: func(r *http.Request) (*http.Response, error) {
+				return nil, errors.New("connection refused")
+			},
+			opts:    sse.Options{MaxReconnects: 2},
+			wantErr: "sse: reconnects exhausted: connection refused",
+		},
+		{
+			name: "no reconnects",
+			doer: func(r *http.Request) (*http.Response, error) {
+				return nil, errors.New("connection refused")
+			},
+			opts:    sse.Options{MaxReconnects: -1},
+			wantErr: "connection refused",
+		},
+		{
+			name:   "event too large",
+			doer:   (&streamsDoer{streams: []string{"data: " + strings.Repeat("a", 100) + "\n\n"}}).Do,
+			opts:   sse.Options{MaxEventSize: 64},
+			wantIs: sse.ErrEventTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Retry = time.Millisecond
+			_, err := collect(t, tt.doer, tt.opts)
+			if err == nil {
+				t.Fatalf("Expected an error")
+			}
+			if tt.wantErr != "" && err.Error() != tt.wantErr {
+				t.Errorf("Expected error %q but got %q", tt.wantErr, err)
+			}
+			if tt.wantIs != nil && !errors.Is(err, tt.wantIs) {
+				t.Errorf("Expected error to be %v but got: %v", tt.wantIs, err)
+			}
+		})
+	}
+}
+
+func TestStream_noTimeout(t *testing.T) {
+	doer := &streamsDoer{streams: []string{"data: a\n\n"}}
+	c := httpc.NewClient(httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+		if _, ok := r.Context().Deadline(); ok {
+			return nil, errors.New("unexpected deadline")
+		}
+		return doer.Do(r)
+	}), httpc.Timeout(time.Millisecond))
+
+	events, err := collect(t, c, sse.Options{MaxReconnects: -1})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(events) != 1 {
+		t.Errorf("Expected 1 event but got %d", len(events))
+	}
+}
+
+func TestStream_break(t *testing.T) {
+	doer := &streamsDoer{streams: []string{"data: a\n\ndata: b\n\n"}}
+	req, err := http.NewRequest(http.MethodGet, "http://localhost/events", http.NoBody)
+	if err != nil {
+		t.Fatalf("Cannot create request: %v", err)
+	}
+
+	for range sse.Stream(doer, req, sse.Options{}) {
+		break
+	}
+
+	if len(doer.bodies) != 1 || !doer.bodies[0].closed {
+		t.Errorf("Expected the stream body to be closed")
+	}
+}
@@ -0,0 +1,148 @@
+package httpc
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+)
+
+// ErrForbiddenDestination indicates that the request destination is in
+// a forbidden network.
+var ErrForbiddenDestination = errors.New("forbidden destination")
+
+// ForbiddenDestinationError is returned for the requests to the addresses
+// forbidden by the [DenyPrivateNetworks] options.
+type ForbiddenDestinationError struct {
+	Host string     // The request host, empty when denied at dial time.
+	IP   netip.Addr // The resolved IP address.
+}
+
+// Error returns a string representation of the error with the address.
+func (e *ForbiddenDestinationError) Error() string {
+	if e.Host == "" || e.Host == e.IP.String() {
+		return fmt.Sprintf("%v: %s", ErrForbiddenDestination, e.IP)
+	}
+	return fmt.Sprintf("%v: %s (%s)", ErrForbiddenDestination, e.Host, e.IP)
+}
+
+// Is compares the [ErrForbiddenDestination] with the target error.
+func (e *ForbiddenDestinationError) Is(target error) bool {
+	return errors.Is(target, ErrForbiddenDestination)
+}
+
+// privateNetworks are the networks denied by default, in addition to the
+// loopback, link-local, private and unspecified addresses.
+var privateNetworks = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // This network (RFC 791).
+	netip.MustParsePrefix("100.64.0.0/10"), // Carrier-grade NAT (RFC 6598).
+}
+
+// PrivateNetworkOptions configures the [DenyPrivateNetworks] middleware and
+// the dial time checks.
+type PrivateNetworkOptions struct {
+	// Deny are the additional denied networks. They take precedence over
+	// the allowed networks.
+	Deny []netip.Prefix
+
+	// Allow are the networks allowed despite being private, e.g. of a proxy
+	// or an internal service.
+	Allow []netip.Prefix
+
+	// Resolver resolves the request hosts for the middleware check.
+	// Defaults to [net.DefaultResolver].
+	Resolver *net.Resolver
+}
+
+// Check returns a [ForbiddenDestinationError] when the address is denied.
+//
+// The loopback, link-local (with the 169.254.169.254 cloud metadata
+// endpoint), private (RFC 1918 and RFC 4193), carrier-grade NAT (RFC 6598)
+// and unspecified addresses are denied by default, as well as the custom
+// denied networks.
+func (o PrivateNetworkOptions) Check(ip netip.Addr) error {
+	ip = ip.Unmap()
+	if o.denied(ip) {
+		return &ForbiddenDestinationError{IP: ip}
+	}
+	return nil
+}
+
+func (o PrivateNetworkOptions) denied(ip netip.Addr) bool {
+	for _, p := range o.Deny {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	for _, p := range o.Allow {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsPrivate() || ip.IsUnspecified() || !ip.IsValid() {
+		return true
+	}
+	for _, p := range privateNetworks {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Control checks the dialed address. It is meant as the [net.Dialer] Control
+// hook of the transport, which covers the addresses resolved at dial time,
+// e.g. after a DNS rebinding, and the redirects followed by the client:
+//
+//	opts := httpc.PrivateNetworkOptions{}
+//	dialer := &net.Dialer{Timeout: 30 * time.Second, Control: opts.Control}
+//	transport := &http.Transport{DialContext: dialer.DialContext}
+//	client := httpc.NewClient(&http.Client{Transport: transport}, httpc.DenyPrivateNetworks(opts))
+//
+// Note that the proxy address is dialed when the transport uses a proxy.
+func (o PrivateNetworkOptions) Control(network, address string, _ syscall.RawConn) error {
+	addr, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s %s", ErrForbiddenDestination, network, address)
+	}
+	return o.Check(addr.Addr())
+}
+
+// DenyPrivateNetworks is a middleware that rejects the requests to hosts in
+// private networks, protecting from server-side request forgery (SSRF) when
+// the URLs are provided by users, with a [ForbiddenDestinationError].
+//
+// The request host is resolved and all of its addresses are checked, see
+// [PrivateNetworkOptions.Check]. The host may resolve to other addresses when
+// dialed, so the middleware should be used with the
+// [PrivateNetworkOptions.Control] dial hook, which is the actual enforcement.
+// Hosts that cannot be resolved are left to fail when dialed.
+func DenyPrivateNetworks(opts PrivateNetworkOptions) MiddlewareFunc {
+	resolver := opts.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	return func(next DoerFunc) DoerFunc {
+		return func(r *http.Request) (*http.Response, error) {
+			host := r.URL.Hostname()
+
+			var ips []netip.Addr
+			if ip, err := netip.ParseAddr(host); err == nil {
+				ips = append(ips, ip)
+			} else if resolved, err := resolver.LookupNetIP(r.Context(), "ip", host); err == nil {
+				ips = resolved
+			}
+
+			for _, ip := range ips {
+				if opts.Check(ip) != nil {
+					return nil, &ForbiddenDestinationError{Host: host, IP: ip.Unmap()}
+				}
+			}
+			return next(r)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package httpc_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/kraciasty/httpc"
+)
+
+func ExampleDenyPrivateNetworks() {
+	c := httpc.NewClient(stubDoer, httpc.DenyPrivateNetworks(httpc.PrivateNetworkOptions{}))
+
+	r, _ := http.NewRequest(http.MethodGet, "http://169.254.169.254/latest/meta-data/", http.NoBody)
+	_, err := c.Do(r)
+	fmt.Println(err)
+	// Output: forbidden destination: 169.254.169.254
+}
+
+func TestPrivateNetworkOptions_Check(t *testing.T) {
+	opts := httpc.PrivateNetworkOptions{
+		Deny:  []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24"), netip.MustParsePrefix("10.1.2.0/24")},
+		Allow: []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16")},
+	}
+
+	tests := []struct {
+		ip     string
+		denied bool
+	}{
+		{"8.8.8.8", false},
+		{"2001:4860:4860::8888", false},
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"0.0.0.0", true},
+		{"0.1.2.3", true},
+		{"::", true},
+		{"10.0.0.1", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"100.64.0.1", true},
+		{"169.254.169.254", true},
+		{"fe80::1", true},
+		{"fd00:ec2::254", true},
+		{"203.0.113.7", true},
+		{"10.1.3.4", false},
+		{"10.1.2.3", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			err := opts.Check(netip.MustParseAddr(tt.ip))
+			if denied := errors.Is(err, httpc.ErrForbiddenDestination); denied != tt.denied {
+				t.Errorf("Expected denied %v but got error: %v", tt.denied, err)
+			}
+		})
+	}
+}
+
+func TestDenyPrivateNetworks(t *testing.T) {
+	tests := []struct {
+		url       string
+		forbidden bool
+		wantErr   string // Not checked when empty.
+	}{
+		{url: "http://8.8.8.8/path"},
+		{url: "http://127.0.0.1:8080/path", forbidden: true, wantErr: "forbidden destination: 127.0.0.1"},
+		{url: "http://[::1]/path", forbidden: true, wantErr: "forbidden destination: ::1"},
+		{url: "http://localhost/path", forbidden: true}, // Resolved to 127.0.0.1 or ::1.
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			c := httpc.NewClient(stubDoer, httpc.DenyPrivateNetworks(httpc.PrivateNetworkOptions{}))
+
+			err := doGet(t, c, context.Background(), tt.url)
+			if !tt.forbidden {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+
+			var forbidden *httpc.ForbiddenDestinationError
+			if !errors.As(err, &forbidden) || !errors.Is(err, httpc.ErrForbiddenDestination) {
+				t.Fatalf("Expected a forbidden destination error but got: %v", err)
+			}
+			if !forbidden.IP.IsLoopback() {
+				t.Errorf("Expected a loopback IP but got %v", forbidden.IP)
+			}
+			if tt.wantErr != "" && err.Error() != tt.wantErr {
+				t.Errorf("Expected error %q but got %q", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPrivateNetworkOptions_Control(t *testing.T) {
+	srv := setupStubServer(t)
+
+	tests := []struct {
+		name    string
+		opts    httpc.PrivateNetworkOptions
+		wantErr bool
+	}{
+		{
+			name:    "denied",
+			wantErr: true,
+		},
+		{
+			name: "allowed",
+			opts: httpc.PrivateNetworkOptions{Allow: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dialer := &net.Dialer{Timeout: time.Second, Control: tt.opts.Control}
+			base := &http.Client{Transport: &http.Transport{DialContext: dialer.DialContext}}
+
+			// The dial hook is enforced without the middleware pre-check,
+			// e.g. when the host resolves to another address when dialed.
+			err := doGet(t, base, context.Background(), srv.URL)
+			if !tt.wantErr {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+
+			var forbidden *httpc.ForbiddenDestinationError
+			if !errors.As(err, &forbidden) || !errors.Is(err, httpc.ErrForbiddenDestination) {
+				t.Fatalf("Expected a forbidden destination error but got: %v", err)
+			}
+			if want := netip.MustParseAddr("127.0.0.1"); forbidden.IP != want {
+				t.Errorf("Expected IP %v but got %v", want, forbidden.IP)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package httpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/kraciasty/httpc/internal/httpbody"
+)
+
+// ErrUnexpectedStatus indicates that the response had an unexpected status.
+//
+// Use the [StatusError] type to retrieve the response details.
+var ErrUnexpectedStatus = errors.New("unexpected status")
+
+// maxStatusErrorBody is the maximum size of the body kept in a [StatusError].
+const maxStatusErrorBody = 8 << 10
+
+// StatusError is returned by the [ErrorOnStatus] middleware for responses with
+// a matching status code.
+type StatusError struct {
+	StatusCode int             // The response status code.
+	Status     string          // The response status, e.g. "404 Not Found".
+	Header     http.Header     // The response headers.
+	Body       []byte          // The beginning of the response body.
+	Problem    *ProblemDetails // The decoded problem details, if any.
+}
+
+// Error returns a string representation of the error with the status and
+// the problem details title and detail, if present.
+func (e *StatusError) Error() string {
+	status := e.Status
+	if status == "" {
+		status = fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+
+	msg := fmt.Sprintf("%v: %s", ErrUnexpectedStatus, status)
+	if e.Problem != nil {
+		if e.Problem.Title != "" {
+			msg += ": " + e.Problem.Title
+		}
+		if e.Problem.Detail != "" {
+			msg += ": " + e.Problem.Detail
+		}
+	}
+	return msg
+}
+
+// Is compares the [ErrUnexpectedStatus] with the target error.
+func (e *StatusError) Is(target error) bool {
+	return errors.Is(target, ErrUnexpectedStatus)
+}
+
+// ProblemDetails is an RFC 9457 problem details object.
+type ProblemDetails struct {
+	Type     string `json:"type,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Status   int    `json:"status,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	// Extensions holds the members other than the standard ones.
+	Extensions map[string]any `json:"-"`
+}
+
+// UnmarshalJSON implements [json.Unmarshaler] and collects the extension
+// members of the problem details.
+func (p *ProblemDetails) UnmarshalJSON(data []byte) error {
+	type problem ProblemDetails
+	if err := json.Unmarshal(data, (*problem)(p)); err != nil {
+		return err
+	}
+
+	var members map[string]any
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+
+	for _, k := range []string{"type", "title", "status", "detail", "instance"} {
+		delete(members, k)
+	}
+	if len(members) > 0 {
+		p.Extensions = members
+	}
+	return nil
+}
+
+// ErrorOnStatus is a middleware that turns responses with a status code
+// matched by the matcher into a [StatusError]. A nil matcher matches the
+// status codes of 400 and above.
+//
+// The error carries the status, the headers and the beginning of the body.
+// Bodies with the application/problem+json content type are decoded into the
+// [ProblemDetails]. The response body is drained and closed.
+func ErrorOnStatus(matcher func(code int) bool) MiddlewareFunc {
+	if matcher == nil {
+		matcher = func(code int) bool { return code >= http.StatusBadRequest }
+	}
+
+	return func(next DoerFunc) DoerFunc {
+		return func(r *http.Request) (*http.Response, error) {
+			resp, err := next(r)
+			if err != nil || !matcher(resp.StatusCode) {
+				return resp, err
+			}
+
+			return nil, newStatusError(resp)
+		}
+	}
+}
+
+// newStatusError returns a [StatusError] for the response. The response body
+// is drained and closed.
+func newStatusError(resp *http.Response) *StatusError {
+	statusErr := &StatusError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+	}
+
+	if resp.Body != nil {
+		statusErr.Body, _ = io.ReadAll(io.LimitReader(resp.Body, maxStatusErrorBody))
+		httpbody.Drain(resp)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "application/problem+json" {
+		var problem ProblemDetails
+		if json.Unmarshal(statusErr.Body, &problem) == nil {
+			statusErr.Problem = &problem
+		}
+	}
+
+	return statusErr
+}
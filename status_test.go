@@ -0,0 +1,154 @@
+package httpc_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kraciasty/httpc"
+)
+
+func ExampleErrorOnStatus() {
+	doer := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusConflict,
+			Header:     http.Header{"Content-Type": {"application/problem+json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"title":"Conflict","detail":"Name taken"}`)),
+		}, nil
+	})
+
+	c := httpc.NewClient(doer, httpc.ErrorOnStatus(nil))
+	r, _ := http.NewRequest(http.MethodPost, "http://stuff.local", http.NoBody)
+	_, err := c.Do(r)
+
+	var statusErr *httpc.StatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusConflict {
+		fmt.Println(statusErr.Problem.Detail)
+	}
+	// Output: Name taken
+}
+
+func TestErrorOnStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		matcher     func(int) bool
+		status      int
+		header      http.Header
+		body        string
+		wantErr     bool
+		wantMsg     string
+		wantProblem *httpc.ProblemDetails
+	}{
+		{
+			name:   "success",
+			status: http.StatusOK,
+			body:   "ok",
+		},
+		{
+			name:    "not found",
+			status:  http.StatusNotFound,
+			body:    "missing",
+			wantErr: true,
+			wantMsg: "unexpected status: 404 Not Found",
+		},
+		{
+			name:    "custom matcher",
+			matcher: func(code int) bool { return code != http.StatusCreated },
+			status:  http.StatusOK,
+			body:    "ok",
+			wantErr: true,
+			wantMsg: "unexpected status: 200 OK",
+		},
+		{
+			name:    "custom matcher passes",
+			matcher: func(code int) bool { return code >= 500 },
+			status:  http.StatusNotFound,
+			body:    "missing",
+		},
+		{
+			name:   "problem details",
+			status: http.StatusConflict,
+			header: http.Header{"Content-Type": {"application/problem+json; charset=utf-8"}},
+			body: `{
+				"type": "https://example.com/probs/out-of-credit",
+				"title": "You do not have enough credit.",
+				"status": 409,
+				"detail": "Your current balance is 30, but that costs 50.",
+				"instance": "/account/12345/msgs/abc",
+				"balance": 30
+			}`,
+			wantErr: true,
+			wantMsg: "unexpected status: 409 Conflict: You do not have enough credit.: Your current balance is 30, but that costs 50.",
+			wantProblem: &httpc.ProblemDetails{
+				Type:       "https://example.com/probs/out-of-credit",
+				Title:      "You do not have enough credit.",
+				Status:     409,
+				Detail:     "Your current balance is 30, but that costs 50.",
+				Instance:   "/account/12345/msgs/abc",
+				Extensions: map[string]any{"balance": 30.0},
+			},
+		},
+		{
+			name:    "invalid problem details",
+			status:  http.StatusBadGateway,
+			header:  http.Header{"Content-Type": {"application/problem+json"}},
+			body:    `<html>`,
+			wantErr: true,
+			wantMsg: "unexpected status: 502 Bad Gateway",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := &trackedBody{Reader: strings.NewReader(tt.body)}
+			doer := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: tt.status, Header: tt.header, Body: body}, nil
+			})
+
+			c := httpc.NewClient(doer, httpc.ErrorOnStatus(tt.matcher))
+			req, err := http.NewRequest(http.MethodGet, "http://localhost", http.NoBody)
+			if err != nil {
+				t.Fatalf("Cannot create request: %v", err)
+			}
+
+			resp, err := c.Do(req)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				defer resp.Body.Close()
+				checkStatus(t, resp, tt.status)
+				return
+			}
+
+			if !errors.Is(err, httpc.ErrUnexpectedStatus) {
+				t.Fatalf("Expected httpc.ErrUnexpectedStatus but got: %v", err)
+			}
+
+			var statusErr *httpc.StatusError
+			if !errors.As(err, &statusErr) {
+				t.Fatalf("Expected error to be of type *httpc.StatusError")
+			}
+
+			if statusErr.StatusCode != tt.status {
+				t.Errorf("Expected status %d but got %d", tt.status, statusErr.StatusCode)
+			}
+			if string(statusErr.Body) != tt.body {
+				t.Errorf("Expected body %q but got %q", tt.body, statusErr.Body)
+			}
+			if got := err.Error(); got != tt.wantMsg {
+				t.Errorf("Expected message %q but got %q", tt.wantMsg, got)
+			}
+			if !reflect.DeepEqual(statusErr.Problem, tt.wantProblem) {
+				t.Errorf("Expected problem %+v but got %+v", tt.wantProblem, statusErr.Problem)
+			}
+			if !body.closed {
+				t.Errorf("Expected body to be closed")
+			}
+		})
+	}
+}
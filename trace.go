@@ -0,0 +1,305 @@
+package httpc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// ErrInvalidTraceParent indicates that a traceparent header is malformed.
+var ErrInvalidTraceParent = errors.New("invalid traceparent")
+
+// FlagSampled is the trace flag indicating that the caller may have recorded
+// the trace.
+const FlagSampled byte = 0x01
+
+// SpanContext identifies a span of a W3C Trace Context trace.
+type SpanContext struct {
+	TraceID [16]byte // The trace ID.
+	SpanID  [8]byte  // The span ID, the parent-id of the traceparent header.
+	Flags   byte     // The trace flags, e.g. [FlagSampled].
+	State   string   // The vendor-specific tracestate header.
+}
+
+// IsValid reports whether the trace and span IDs are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// Sampled reports whether the [FlagSampled] flag is set.
+func (sc SpanContext) Sampled() bool {
+	return sc.Flags&FlagSampled != 0
+}
+
+// TraceParent returns the traceparent header value of the span context.
+func (sc SpanContext) TraceParent() string {
+	return fmt.Sprintf("00-%x-%x-%02x", sc.TraceID, sc.SpanID, sc.Flags)
+}
+
+// ParseTraceParent parses the traceparent header value. Values with a future
+// version are parsed as version 00, ignoring the additional fields.
+func ParseTraceParent(s string) (SpanContext, error) {
+	var sc SpanContext
+	if len(s) < 55 || s[2] != '-' || s[35] != '-' || s[52] != '-' {
+		return sc, fmt.Errorf("%w: %q", ErrInvalidTraceParent, s)
+	}
+
+	version, ok := parseLowerHex(s[:2], 1)
+	switch {
+	case !ok || version[0] == 0xff:
+		return sc, fmt.Errorf("%w: version %q", ErrInvalidTraceParent, s[:2])
+	case version[0] == 0 && len(s) != 55:
+		return sc, fmt.Errorf("%w: %q", ErrInvalidTraceParent, s)
+	case len(s) > 55 && s[55] != '-':
+		return sc, fmt.Errorf("%w: %q", ErrInvalidTraceParent, s)
+	}
+
+	traceID, ok := parseLowerHex(s[3:35], 16)
+	if !ok {
+		return sc, fmt.Errorf("%w: trace-id %q", ErrInvalidTraceParent, s[3:35])
+	}
+	spanID, ok := parseLowerHex(s[36:52], 8)
+	if !ok {
+		return sc, fmt.Errorf("%w: parent-id %q", ErrInvalidTraceParent, s[36:52])
+	}
+	flags, ok := parseLowerHex(s[53:55], 1)
+	if !ok {
+		return sc, fmt.Errorf("%w: trace-flags %q", ErrInvalidTraceParent, s[53:55])
+	}
+
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	sc.Flags = flags[0]
+	if !sc.IsValid() {
+		return SpanContext{}, fmt.Errorf("%w: zero trace-id or parent-id", ErrInvalidTraceParent)
+	}
+	return sc, nil
+}
+
+// SpanContextFromHeader returns the span context of the traceparent and
+// tracestate headers, e.g. of an inbound server request.
+func SpanContextFromHeader(h http.Header) (SpanContext, error) {
+	sc, err := ParseTraceParent(h.Get("Traceparent"))
+	if err != nil {
+		return sc, err
+	}
+	sc.State = h.Get("Tracestate")
+	return sc, nil
+}
+
+func parseLowerHex(s string, n int) ([]byte, bool) {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return nil, false
+		}
+	}
+	b, err := hex.DecodeString(s)
+	return b, err == nil && len(b) == n
+}
+
+// spanContext is the span context option in the request context.
+var spanContext = NewRequestOption[SpanContext]("span context")
+
+// ContextWithSpan returns a copy of the context with the span context, e.g.
+// of an inbound server request, to be the parent of the spans created by the
+// [TraceContext] middleware.
+func ContextWithSpan(ctx context.Context, sc SpanContext) context.Context {
+	return spanContext.With(ctx, sc)
+}
+
+// SpanFromContext returns the span context from the context and reports
+// whether a valid one was set.
+func SpanFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := spanContext.Value(ctx)
+	return sc, ok && sc.IsValid()
+}
+
+// Span is a client span of a request, recorded by the [TraceContext]
+// middleware.
+type Span struct {
+	Context SpanContext // The span context sent in the traceparent header.
+	Parent  SpanContext // The parent span context, zero for a root span.
+
+	Request    *http.Request // The request sent.
+	StatusCode int           // The response status code, zero on error.
+	Err        error         // The error of the chain, if any.
+
+	Start time.Time // The start of the request.
+	End   time.Time // The time the response headers were received.
+
+	// The connection timings reported by [httptrace]. They are zero for the
+	// phases that did not happen, e.g. on a reused connection or when the
+	// request did not reach the transport.
+	DNSStart          time.Time
+	DNSDone           time.Time
+	ConnectStart      time.Time
+	ConnectDone       time.Time
+	TLSHandshakeStart time.Time
+	TLSHandshakeDone  time.Time
+	GotConn           time.Time
+	WroteRequest      time.Time
+	FirstByte         time.Time
+
+	// ConnReused reports whether the connection was reused from the pool.
+	ConnReused bool
+}
+
+// Duration returns the duration of the span.
+func (s *Span) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// SpanRecorder records the spans of the [TraceContext] middleware, e.g. to
+// export them to a tracing backend.
+type SpanRecorder interface {
+	RecordSpan(s *Span)
+}
+
+// SpanRecorderFunc is an adapter to allow the use of ordinary functions as
+// a [SpanRecorder].
+type SpanRecorderFunc func(s *Span)
+
+// RecordSpan calls f(s).
+func (f SpanRecorderFunc) RecordSpan(s *Span) {
+	f(s)
+}
+
+// TraceContext is a middleware that propagates the W3C Trace Context.
+//
+// Each request is sent with a new child span in the traceparent header, whose
+// parent is the span of the request context, see [ContextWithSpan], or the
+// traceparent header already set on the request. The tracestate header is
+// propagated from the parent. Without a parent, a new sampled trace is
+// started. The span context is stored in the request context for the next
+// middlewares, see [SpanFromContext].
+//
+// The spans are recorded with rec, if not nil, after the response headers
+// are received, with the connection timings collected with [httptrace].
+// All spans are recorded, the recorder can honor the caller's sampling
+// decision with [SpanContext.Sampled].
+//
+// Place the middleware after [Retry] to record a span per attempt.
+func TraceContext(rec SpanRecorder) MiddlewareFunc {
+	return func(next DoerFunc) DoerFunc {
+		return func(r *http.Request) (*http.Response, error) {
+			ctx := r.Context()
+			parent, ok := SpanFromContext(ctx)
+			if !ok {
+				parent, _ = SpanContextFromHeader(r.Header)
+			}
+
+			sc := parent
+			sc.SpanID = newSpanID()
+			if !parent.IsValid() {
+				_, _ = rand.Read(sc.TraceID[:])
+				sc.Flags = FlagSampled
+			}
+
+			r = r.Clone(ContextWithSpan(ctx, sc))
+			r.Header.Set("Traceparent", sc.TraceParent())
+			if sc.State != "" {
+				r.Header.Set("Tracestate", sc.State)
+			} else {
+				r.Header.Del("Tracestate")
+			}
+
+			if rec == nil {
+				return next(r)
+			}
+
+			span := &Span{Context: sc, Parent: parent, Start: time.Now()}
+			t := &spanTrace{span: span}
+			r = r.WithContext(httptrace.WithClientTrace(r.Context(), t.clientTrace()))
+			span.Request = r
+
+			resp, err := next(r)
+
+			t.mu.Lock()
+			span.End = time.Now()
+			span.Err = err
+			if resp != nil {
+				span.StatusCode = resp.StatusCode
+			}
+			t.done = true
+			t.mu.Unlock()
+
+			rec.RecordSpan(span)
+			return resp, err
+		}
+	}
+}
+
+func newSpanID() [8]byte {
+	var id [8]byte
+	for id == [8]byte{} {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+// spanTrace collects the [httptrace] timings of a span. The hooks may be
+// called concurrently, e.g. when dialing multiple addresses, and after the
+// span ended, so the updates are guarded.
+type spanTrace struct {
+	mu   sync.Mutex
+	span *Span
+	done bool
+}
+
+func (t *spanTrace) set(fn func(s *Span)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.done {
+		fn(t.span)
+	}
+}
+
+func (t *spanTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.set(func(s *Span) { s.DNSStart = time.Now() })
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.set(func(s *Span) { s.DNSDone = time.Now() })
+		},
+		ConnectStart: func(string, string) {
+			t.set(func(s *Span) {
+				if s.ConnectStart.IsZero() {
+					s.ConnectStart = time.Now()
+				}
+			})
+		},
+		ConnectDone: func(_, _ string, err error) {
+			t.set(func(s *Span) {
+				if err == nil {
+					s.ConnectDone = time.Now()
+				}
+			})
+		},
+		TLSHandshakeStart: func() {
+			t.set(func(s *Span) { s.TLSHandshakeStart = time.Now() })
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.set(func(s *Span) { s.TLSHandshakeDone = time.Now() })
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.set(func(s *Span) {
+				s.GotConn = time.Now()
+				s.ConnReused = info.Reused
+			})
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.set(func(s *Span) { s.WroteRequest = time.Now() })
+		},
+		GotFirstResponseByte: func() {
+			t.set(func(s *Span) { s.FirstByte = time.Now() })
+		},
+	}
+}
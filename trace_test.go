@@ -0,0 +1,243 @@
+package httpc_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/kraciasty/httpc"
+)
+
+func ExampleTraceContext() {
+	rec := httpc.SpanRecorderFunc(func(s *httpc.Span) {
+		fmt.Println(s.Parent.TraceParent(), s.StatusCode)
+	})
+	c := httpc.NewClient(stubDoer, httpc.TraceContext(rec))
+
+	// Propagate the trace of an inbound request.
+	inbound := http.Header{"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}}
+	parent, _ := httpc.SpanContextFromHeader(inbound)
+	ctx := httpc.ContextWithSpan(context.Background(), parent)
+
+	r, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://stuff.local", http.NoBody)
+	resp, _ := c.Do(r)
+	defer resp.Body.Close()
+	// Output: 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01 200
+}
+
+func TestParseTraceParent(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "valid",
+			value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			want:  "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		},
+		{
+			name:  "future version",
+			value: "cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-09-extra",
+			want:  "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-09",
+		},
+		{name: "empty", value: "", wantErr: true},
+		{name: "invalid version", value: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", wantErr: true},
+		{name: "version 00 with extra", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", wantErr: true},
+		{name: "uppercase", value: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", wantErr: true},
+		{name: "zero trace id", value: "00-00000000000000000000000000000000-00f067aa0ba902b7-01", wantErr: true},
+		{name: "zero parent id", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", wantErr: true},
+		{name: "invalid flags", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0x", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, err := httpc.ParseTraceParent(tt.value)
+			if tt.wantErr {
+				if !errors.Is(err, httpc.ErrInvalidTraceParent) {
+					t.Errorf("Expected httpc.ErrInvalidTraceParent but got: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got := sc.TraceParent(); got != tt.want {
+				t.Errorf("Expected traceparent %q but got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTraceContext(t *testing.T) {
+	const (
+		traceID  = "4bf92f3577b34da6a3ce929d0e0e4736"
+		parentID = "00f067aa0ba902b7"
+	)
+	inbound := "00-" + traceID + "-" + parentID + "-00"
+	parent, err := httpc.ParseTraceParent(inbound)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	parent.State = "vendor=value"
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		header    http.Header
+		wantTrace string
+		wantFlags string
+		wantState string
+	}{
+		{
+			name:      "root",
+			ctx:       context.Background(),
+			wantFlags: "01",
+		},
+		{
+			name:      "from context",
+			ctx:       httpc.ContextWithSpan(context.Background(), parent),
+			header:    http.Header{"Tracestate": {"stale=1"}},
+			wantTrace: traceID,
+			wantFlags: "00",
+			wantState: "vendor=value",
+		},
+		{
+			name: "from header",
+			ctx:  context.Background(),
+			header: http.Header{
+				"Traceparent": {"00-" + traceID + "-" + parentID + "-01"},
+				"Tracestate":  {"other=1"},
+			},
+			wantTrace: traceID,
+			wantFlags: "01",
+			wantState: "other=1",
+		},
+	}
+
+	re := regexp.MustCompile(`^00-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})$`)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got http.Header
+			var fromCtx httpc.SpanContext
+			doer := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+				got = r.Header.Clone()
+				fromCtx, _ = httpc.SpanFromContext(r.Context())
+				return stubDoer(r)
+			})
+			c := httpc.NewClient(doer, httpc.TraceContext(nil))
+
+			r, err := http.NewRequestWithContext(tt.ctx, http.MethodGet, "http://localhost", http.NoBody)
+			if err != nil {
+				t.Fatalf("Cannot create request: %v", err)
+			}
+			for k, v := range tt.header {
+				r.Header[k] = v
+			}
+
+			resp, err := c.Do(r)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			defer resp.Body.Close()
+
+			m := re.FindStringSubmatch(got.Get("Traceparent"))
+			if m == nil {
+				t.Fatalf("Invalid traceparent %q", got.Get("Traceparent"))
+			}
+			if tt.wantTrace != "" && m[1] != tt.wantTrace {
+				t.Errorf("Expected trace-id %q but got %q", tt.wantTrace, m[1])
+			}
+			if m[2] == parentID {
+				t.Errorf("Expected a child span ID but got the parent's")
+			}
+			if m[3] != tt.wantFlags {
+				t.Errorf("Expected trace-flags %q but got %q", tt.wantFlags, m[3])
+			}
+			if s := got.Get("Tracestate"); s != tt.wantState {
+				t.Errorf("Expected tracestate %q but got %q", tt.wantState, s)
+			}
+			if fromCtx.TraceParent() != got.Get("Traceparent") {
+				t.Errorf("Expected span context %q in the context but got %q", got.Get("Traceparent"), fromCtx.TraceParent())
+			}
+			if r.Header.Get("Traceparent") != tt.header.Get("Traceparent") {
+				t.Errorf("Expected the original request to be unchanged")
+			}
+		})
+	}
+}
+
+func TestTraceContext_record(t *testing.T) {
+	srv := setupStubServer(t)
+
+	var mu sync.Mutex
+	var spans []*httpc.Span
+	rec := httpc.SpanRecorderFunc(func(s *httpc.Span) {
+		mu.Lock()
+		defer mu.Unlock()
+		spans = append(spans, s)
+	})
+	c := httpc.NewClient(srv.Client(), httpc.TraceContext(rec))
+
+	for range 2 {
+		if err := doGet(t, c, context.Background(), srv.URL); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans but got %d", len(spans))
+	}
+
+	first, second := spans[0], spans[1]
+	if first.StatusCode != http.StatusOK || first.Err != nil {
+		t.Errorf("Expected a successful span but got status %d and error %v", first.StatusCode, first.Err)
+	}
+	if first.Parent.IsValid() || !first.Context.Sampled() {
+		t.Errorf("Expected a sampled root span but got %+v", first.Context)
+	}
+	if first.Request.Header.Get("Traceparent") != first.Context.TraceParent() {
+		t.Errorf("Expected the span request to carry the span context")
+	}
+	for name, ts := range map[string][2]int64{
+		"connect":    {first.ConnectStart.UnixNano(), first.ConnectDone.UnixNano()},
+		"first byte": {first.WroteRequest.UnixNano(), first.FirstByte.UnixNano()},
+		"span":       {first.Start.UnixNano(), first.End.UnixNano()},
+	} {
+		if ts[0] <= 0 || ts[1] < ts[0] {
+			t.Errorf("Expected %s timings to be recorded in order but got %v", name, ts)
+		}
+	}
+	if first.ConnReused || !second.ConnReused {
+		t.Errorf("Expected the second connection to be reused")
+	}
+	if !second.ConnectStart.IsZero() {
+		t.Errorf("Expected no connect timings on a reused connection")
+	}
+	if first.Duration() <= 0 {
+		t.Errorf("Expected a positive duration but got %v", first.Duration())
+	}
+}
+
+func TestTraceContext_recordError(t *testing.T) {
+	errBoom := errors.New("boom")
+	var span *httpc.Span
+	c := httpc.NewClient(
+		httpc.DoerFunc(func(*http.Request) (*http.Response, error) { return nil, errBoom }),
+		httpc.TraceContext(httpc.SpanRecorderFunc(func(s *httpc.Span) { span = s })),
+	)
+
+	if err := doGet(t, c, context.Background(), "http://localhost"); !errors.Is(err, errBoom) {
+		t.Fatalf("Expected the chain error but got: %v", err)
+	}
+	if span == nil || !errors.Is(span.Err, errBoom) || span.StatusCode != 0 {
+		t.Errorf("Expected a span with the error but got %+v", span)
+	}
+}